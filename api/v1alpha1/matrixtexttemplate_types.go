@@ -0,0 +1,148 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	MatrixTextTemplateTargetConfigMap = "ConfigMap"
+	MatrixTextTemplateTargetSecret    = "Secret"
+)
+
+// MatrixTextTemplateSpec defines the desired state of MatrixTextTemplate
+type MatrixTextTemplateSpec struct {
+	// +kubebuilder:default:="30s"
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	Interval metav1.Duration `json:"interval"`
+
+	// Suspend can be used to suspend the reconciliation of this object.
+	// +optional
+	// +kubebuilder:default:=false
+	Suspend bool `json:"suspend"`
+
+	// The name of the Kubernetes service account to impersonate
+	// when reconciling this MatrixTextTemplate. If omitted, the "default" service account is used.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Matrix specifies the input matrix. It supports the same inputs as the matrix of ObjectTemplates
+	// +required
+	Matrix []*MatrixEntry `json:"matrix"`
+
+	// MatrixFilter specifies an optional Jinja2 expression that is evaluated for each entry of the multiplied matrix.
+	// Entries for which the expression evaluates to a falsy value are dropped before rendering
+	// +optional
+	MatrixFilter string `json:"matrixFilter,omitempty"`
+
+	// StrictUndefined enables strict handling of undefined variables while rendering, the same way as for ObjectTemplates
+	// +kubebuilder:default:=false
+	// +optional
+	StrictUndefined bool `json:"strictUndefined,omitempty"`
+
+	// Vars specifies additional variables that are available in the template, matrix list entries and expressions. It
+	// supports the same sources as the vars of ObjectTemplates
+	// +optional
+	Vars []*VarsSource `json:"vars,omitempty"`
+
+	// Template is a Jinja2 template that is rendered once per matrix entry
+	// +required
+	Template string `json:"template"`
+
+	// Separator is inserted between the rendered results of the individual matrix entries
+	// +kubebuilder:default:="\n"
+	// +optional
+	Separator string `json:"separator,omitempty"`
+
+	// Target specifies the ConfigMap or Secret that receives the concatenated result
+	// +required
+	Target MatrixTextTemplateTarget `json:"target"`
+}
+
+type MatrixTextTemplateTarget struct {
+	// Kind is the kind of the target object, either ConfigMap or Secret
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	// +kubebuilder:default:="ConfigMap"
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name is the name of the target object. It is always created in the namespace of the MatrixTextTemplate
+	// +required
+	Name string `json:"name"`
+
+	// Key is the data key that receives the result
+	// +required
+	Key string `json:"key"`
+}
+
+// MatrixTextTemplateStatus defines the observed state of MatrixTextTemplate
+type MatrixTextTemplateStatus struct {
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Warnings contains non-fatal problems encountered while building the matrix, e.g. missing optional inputs
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+
+	// MatrixEntries is the number of matrix entries that were rendered in the last successful reconciliation
+	// +optional
+	MatrixEntries int `json:"matrixEntries,omitempty"`
+
+	// FailureCount is the number of consecutive failed reconciliations. It is used to calculate the backoff until the
+	// next reconciliation and is reset after a successful reconciliation
+	// +optional
+	FailureCount int `json:"failureCount,omitempty"`
+}
+
+// GetConditions returns the status conditions of the object.
+func (in *MatrixTextTemplate) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+// SetConditions sets the status conditions on the object.
+func (in *MatrixTextTemplate) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MatrixTextTemplate is the Schema for the matrixtexttemplates API
+type MatrixTextTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MatrixTextTemplateSpec   `json:"spec,omitempty"`
+	Status MatrixTextTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MatrixTextTemplateList contains a list of MatrixTextTemplate
+type MatrixTextTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MatrixTextTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MatrixTextTemplate{}, &MatrixTextTemplateList{})
+}
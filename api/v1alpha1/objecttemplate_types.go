@@ -20,10 +20,36 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
 )
 
 const (
 	ObjectTemplateFinalizer = "finalizers.templates.kluctl.io"
+
+	// ApplyWaveAnnotation can be set on rendered objects to control the order in which objects are applied. Objects
+	// are applied in waves, ordered by the integer value of this annotation. Objects without the annotation are in
+	// wave 0.
+	ApplyWaveAnnotation = "templates.kluctl.io/apply-wave"
+
+	// PruneAnnotation can be set to PruneDisabled on applied objects to prevent these from being deleted by pruning or
+	// finalization. Such objects are orphaned instead.
+	PruneAnnotation = "templates.kluctl.io/prune"
+	PruneDisabled   = "disabled"
+
+	// PruneOrderAnnotation can be set on rendered objects to control the order in which objects are deleted by pruning
+	// and finalization. Objects are deleted in ascending order of the integer value of this annotation, the next group
+	// is only deleted after all objects of the previous group are gone. Defaults to the negated value of the
+	// apply-wave annotation, so that objects are deleted in the reverse order of how they were applied.
+	PruneOrderAnnotation = "templates.kluctl.io/prune-order"
+
+	// ShardLabel assigns templates to a controller shard. Each controller instance started with --shard only
+	// reconciles templates with a matching label, the unsharded instance only reconciles templates without this label.
+	ShardLabel = "templates.kluctl.io/shard"
+
+	// ReconcileRequestAnnotation can be set to an arbitrary value (e.g. the current time) to request an immediate
+	// reconciliation. Each change of the value triggers a reconciliation.
+	ReconcileRequestAnnotation = "reconcile.templates.kluctl.io/requestedAt"
 )
 
 // ObjectTemplateSpec defines the desired state of ObjectTemplate
@@ -33,6 +59,14 @@ type ObjectTemplateSpec struct {
 	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
 	Interval metav1.Duration `json:"interval"`
 
+	// Timeout specifies the maximum duration of a single reconciliation, including loading inputs, rendering and
+	// applying. If the timeout is exceeded, the reconciliation is aborted and retried later. If omitted, no timeout
+	// is applied
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
 	// Suspend can be used to suspend the reconciliation of this object
 	// +optional
 	// +kubebuilder:default:=false
@@ -43,25 +77,335 @@ type ObjectTemplateSpec struct {
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 
+	// KubeConfig specifies a kubeconfig that is used to apply rendered objects into a remote cluster. Matrix inputs are
+	// still loaded from the local cluster. If omitted, objects are applied into the local cluster
+	// +optional
+	KubeConfig *KubeConfig `json:"kubeConfig,omitempty"`
+
+	// TargetNamespace optionally overrides the namespace of all rendered namespaced objects, including objects that
+	// specify a namespace themselves. Cluster-scoped objects are not affected
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// FieldManager optionally overrides the field manager used when applying rendered objects. If omitted, the
+	// default field manager of the controller is used
+	// +kubebuilder:validation:Pattern="^[^\\s]+$"
+	// +kubebuilder:validation:MaxLength=128
+	// +optional
+	FieldManager string `json:"fieldManager,omitempty"`
+
+	// FieldManagerByKind optionally overrides the field manager per kind of the rendered objects. Keys are in the form
+	// `<kind>.<group>` (e.g. `Deployment.apps`) or `<kind>` for the core group (e.g. `ConfigMap`). Objects of kinds
+	// not listed here use the field manager specified via fieldManager or the default one
+	// +optional
+	FieldManagerByKind map[string]string `json:"fieldManagerByKind,omitempty"`
+
+	// ForceApply enables forcing of ownership when applying rendered objects. This causes the controller to take
+	// ownership of fields that are owned by other field managers instead of failing with a conflict. Use with care
+	// +kubebuilder:default:=false
+	// +optional
+	ForceApply bool `json:"forceApply,omitempty"`
+
+	// ConflictPolicy specifies how conflicts with other field managers are handled when applying rendered objects.
+	// `fail` reports the conflict as an error, `force` takes ownership of the conflicting fields and `ignore` leaves
+	// the conflicting fields out of the applied object, so that the other field managers keep ownership. If omitted,
+	// `force` is used when forceApply is true and `fail` otherwise
+	// +kubebuilder:validation:Enum=fail;force;ignore
+	// +optional
+	ConflictPolicy string `json:"conflictPolicy,omitempty"`
+
+	// ExcludeFields specifies fields that are removed from rendered objects before these are applied, so that the
+	// controller does not take ownership of these fields. This is useful for fields that are also managed by other
+	// controllers or admission mutators, e.g. spec.replicas of a Deployment that is scaled by an autoscaler
+	// +optional
+	ExcludeFields []*ExcludeFields `json:"excludeFields,omitempty"`
+
+	// ApplyTimeout specifies the timeout for applying a single rendered object. Objects that can not be applied in
+	// time are reported as failed while the remaining objects are still applied
+	// +kubebuilder:default:="1m"
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	ApplyTimeout metav1.Duration `json:"applyTimeout,omitempty"`
+
+	// ApplyRetries specifies how often applying a single object is retried when it fails with a transient error, e.g.
+	// a conflict or a timeout. Retries happen with exponential backoff inside the same reconciliation
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	ApplyRetries int `json:"applyRetries,omitempty"`
+
+	// MaxResources limits the number of objects that may be rendered. If more objects are rendered, the reconciliation
+	// fails before anything is applied or pruned. The controller may enforce a lower limit
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxResources *int `json:"maxResources,omitempty"`
+
 	// Prune enables pruning of previously created objects when these disappear from the list of rendered objects
 	// +kubebuilder:default:=false
 	// +optional
 	Prune bool `json:"prune"`
 
+	// PruneGracePeriod specifies how long an object must be absent from the rendered objects before it is pruned. This
+	// avoids deleting and re-creating objects when matrix inputs briefly return fewer elements, e.g. while the source
+	// of the matrix is updated. Objects waiting to be pruned are marked via pruneCandidateSince in
+	// status.appliedResources
+	// +optional
+	PruneGracePeriod *metav1.Duration `json:"pruneGracePeriod,omitempty"`
+
+	// SetOwnerReferences enables setting a controller owner reference pointing to this ObjectTemplate on all applied
+	// objects, so that the Kubernetes garbage collector deletes them when the ObjectTemplate is deleted. Owner
+	// references are only set on namespaced objects in the same namespace as the ObjectTemplate and only when
+	// applying into the local cluster
+	// +kubebuilder:default:=false
+	// +optional
+	SetOwnerReferences bool `json:"setOwnerReferences,omitempty"`
+
+	// DetectDrift enables detection of out-of-band changes to applied objects. Before applying, all rendered objects
+	// are applied with server-side dry-run and compared to the live objects. Differences are reported via the
+	// Drifted condition and status.driftedResources
+	// +kubebuilder:default:=false
+	// +optional
+	DetectDrift bool `json:"detectDrift,omitempty"`
+
+	// DryRun enables dry-run mode. In dry-run mode, rendered objects are applied with server-side dry-run and the
+	// results are written to status.dryRunResults instead of modifying the cluster. Pruning is also skipped and the
+	// objects that would have been pruned are listed in the results instead
+	// +kubebuilder:default:=false
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Wait enables waiting for all applied objects to become ready before the ObjectTemplate itself is marked as ready.
+	// When apply waves are used, each wave must become ready before the next wave is applied.
+	// +optional
+	Wait *ObjectTemplateWait `json:"wait,omitempty"`
+
+	// HealthChecks specifies custom readiness checks per kind, which are used instead of kstatus while waiting for
+	// applied objects. Only used when wait is set
+	// +optional
+	HealthChecks []HealthCheck `json:"healthChecks,omitempty"`
+
+	// Debug specifies debugging options
+	// +optional
+	Debug *ObjectTemplateDebug `json:"debug,omitempty"`
+
+	// Inventory optionally specifies where to store the list of applied objects in a machine-readable format
+	// +optional
+	Inventory *ObjectTemplateInventory `json:"inventory,omitempty"`
+
+	// ValidateSchema enables validation of rendered objects against the OpenAPI schema of the target CRD before they
+	// are applied. Objects of kinds that are not backed by a CRD are not validated against a schema.
+	// +kubebuilder:default:=false
+	// +optional
+	ValidateSchema bool `json:"validateSchema,omitempty"`
+
+	// CommonMetadata specifies labels and annotations that are added to all rendered objects before these are applied.
+	// Labels and annotations set by the templates themselves take precedence
+	// +optional
+	CommonMetadata *CommonMetadata `json:"commonMetadata,omitempty"`
+
+	// StrictUndefined enables strict handling of undefined variables while rendering. Accessing an undefined variable
+	// then fails the reconciliation instead of silently rendering an empty value
+	// +kubebuilder:default:=false
+	// +optional
+	StrictUndefined bool `json:"strictUndefined,omitempty"`
+
+	// CacheRenderedObjects enables caching of the rendered objects. Templates are then only rendered again when the
+	// spec, labels or annotations of the ObjectTemplate, the matrix, vars or referenced ConfigMaps change. Rendered
+	// objects are still applied on every reconciliation. Templates using existingRef are never cached
+	// +kubebuilder:default:=false
+	// +optional
+	CacheRenderedObjects bool `json:"cacheRenderedObjects,omitempty"`
+
+	// SkipUnchangedSources enables skipping of reconciliations when neither the ObjectTemplate nor any of the objects it
+	// reads from changed since the last successful reconciliation. Only supported when all inputs can be tracked by
+	// their resourceVersion, which excludes objectList, namespaces, http and git matrix entries, templated object refs
+	// and templates using existingRef. Reconciliations are not skipped while prunes are pending or when applied objects
+	// were deleted. Other changes to applied objects are not corrected while reconciliations are skipped
+	// +kubebuilder:default:=false
+	// +optional
+	SkipUnchangedSources bool `json:"skipUnchangedSources,omitempty"`
+
+	// Vars specifies additional variables that are available in all templates, matrix list entries and expressions.
+	// Variables loaded from ConfigMaps and Secrets are merged first, in the order specified, and inline values are
+	// merged afterwards, so that inline values take precedence. Variables never override `objectTemplate` or `matrix`
+	// +optional
+	Vars []*VarsSource `json:"vars,omitempty"`
+
+	// FiltersConfigMapRef optionally refers a ConfigMap in the same namespace that contains custom Jinja2 filters. Each
+	// key specifies the filter name and the value must contain the Python code defining a function with the same name.
+	// Custom filters must be enabled in the controller via --enable-custom-jinja2-filters
+	// +optional
+	FiltersConfigMapRef *LocalObjectReference `json:"filtersConfigMapRef,omitempty"`
+
+	// IncludesConfigMapRef optionally refers a ConfigMap in the same namespace that contains a library of Jinja2
+	// templates. Each key is made available as a template with the same name, which can then be used via
+	// {% include %} and {% import %} in all templates
+	// +optional
+	IncludesConfigMapRef *LocalObjectReference `json:"includesConfigMapRef,omitempty"`
+
 	// Matrix specifies the input matrix
 	// +required
 	Matrix []*MatrixEntry `json:"matrix"`
 
+	// MatrixFilter specifies an optional Jinja2 expression that is evaluated for each entry of the multiplied matrix.
+	// Entries for which the expression evaluates to a falsy value are dropped before rendering. The expression has
+	// access to the same variables as templates, including `matrix`
+	// +optional
+	MatrixFilter string `json:"matrixFilter,omitempty"`
+
 	// Templates specifies a list of templates to render and deploy
 	// +required
 	Templates []Template `json:"templates"`
 }
 
+type CommonMetadata struct {
+	// Labels specifies the labels to add to all rendered objects
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations specifies the annotations to add to all rendered objects
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ObjectTemplateWait struct {
+	// Timeout specifies the maximum duration to wait for all applied objects to become ready.
+	// +kubebuilder:default:="5m"
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+type HealthCheck struct {
+	// Group specifies the API group of the objects this health check applies to. Use an empty string for the core
+	// group
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind specifies the kind of the objects this health check applies to
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Kind string `json:"kind"`
+
+	// Ready specifies a Jinja2 expression that is evaluated against the live object, which is available as `object`.
+	// The object is considered ready when the expression is true, e.g. `object.status.readyReplicas == object.spec.replicas`
+	// +kubebuilder:validation:MinLength=1
+	// +required
+	Ready string `json:"ready"`
+
+	// Failed optionally specifies a Jinja2 expression that marks the object as failed when true, which aborts waiting
+	// immediately instead of waiting for the timeout
+	// +optional
+	Failed string `json:"failed,omitempty"`
+}
+
+type KubeConfig struct {
+	// SecretRef refers a Secret in the same namespace as the ObjectTemplate that contains the kubeconfig. The service
+	// account used by the ObjectTemplate must have proper permissions to get this Secret
+	// +required
+	SecretRef KubeConfigSecretRef `json:"secretRef"`
+}
+
+type KubeConfigSecretRef struct {
+	// Name specifies the name of the Secret
+	// +required
+	Name string `json:"name"`
+
+	// Key specifies the key inside the Secret that contains the kubeconfig
+	// +kubebuilder:default:="value"
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+type ExcludeFields struct {
+	// Group optionally restricts this entry to objects of the given API group. Use an empty string for the core
+	// group. If omitted, objects of all groups are matched
+	// +optional
+	Group *string `json:"group,omitempty"`
+
+	// Kind optionally restricts this entry to objects of the given kind
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name optionally restricts this entry to objects with the given name
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// JsonPaths specifies the fields to remove from matching objects, e.g. `spec.replicas`
+	// +kubebuilder:validation:MinItems=1
+	// +required
+	JsonPaths []string `json:"jsonPaths"`
+}
+
+type ObjectTemplateDebug struct {
+	// StoreRenderedOutput enables storing of the rendered objects in a ConfigMap named `<name>-rendered`. The output
+	// is truncated if it gets too large. Data of rendered Secrets and values loaded from Secrets are redacted
+	// +kubebuilder:default:=false
+	// +optional
+	StoreRenderedOutput bool `json:"storeRenderedOutput,omitempty"`
+}
+
+type ObjectTemplateInventory struct {
+	// ConfigMapRef refers a ConfigMap in the same namespace as the ObjectTemplate. The applied and orphaned objects
+	// are written as JSON into the `inventory.json` key of the ConfigMap on every reconciliation
+	// +required
+	ConfigMapRef LocalObjectReference `json:"configMapRef"`
+}
+
+type VarsSource struct {
+	// Values specifies inline variables
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Values *runtime.RawExtension `json:"values,omitempty"`
+
+	// ConfigMap specifies a ConfigMap key in the same namespace that contains a YAML/JSON map of variables
+	// +optional
+	ConfigMap *VarsSourceRef `json:"configMap,omitempty"`
+
+	// Secret specifies a Secret key in the same namespace that contains a YAML/JSON map of variables. Values loaded
+	// from Secrets are scrubbed from errors and status messages
+	// +optional
+	Secret *VarsSourceRef `json:"secret,omitempty"`
+}
+
+type VarsSourceRef struct {
+	// Name of the ConfigMap or Secret
+	// +required
+	Name string `json:"name"`
+
+	// Key specifies the data key to load
+	// +required
+	Key string `json:"key"`
+}
+
 type MatrixEntry struct {
 	// Name specifies the name this matrix input is available while rendering templates
+	// +kubebuilder:validation:MinLength=1
 	// +required
 	Name string `json:"name"`
 
+	// Optional specifies that a missing object (object, configMap or secret) should not fail the reconciliation.
+	// Instead, this matrix input is left out of the matrix and a warning is recorded in the status
+	// +kubebuilder:default:=false
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+
+	// Defaults optionally specifies a map of default values that is deep-merged under each element of this matrix
+	// input. Values of the element take precedence. Elements that are not maps are left untouched
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Defaults *runtime.RawExtension `json:"defaults,omitempty"`
+
+	// DistinctBy optionally specifies a Jinja2 expression that is evaluated for each element of this matrix input, with
+	// the element being available as `element`. Elements that result in a key that was already returned by a previous
+	// element are removed, so that only the first occurrence of each key is kept
+	// +optional
+	DistinctBy string `json:"distinctBy,omitempty"`
+
 	// Object specifies an object to load and make available while rendering templates. The object can be accessed
 	// through the name specified above. The service account used by the ObjectTemplate must have proper permissions
 	// to get this object
@@ -73,6 +417,56 @@ type MatrixEntry struct {
 	// +optional
 	// +kubebuilder:pruning:PreserveUnknownFields
 	List []runtime.RawExtension `json:"list,omitempty"`
+
+	// ElementType optionally specifies the type each rendered element of List is coerced to. Can be `string`, `int`,
+	// `bool` or `object`. For object elements, the scalar types are applied to each top-level value of the element.
+	// Without it, the types are whatever YAML parsing of the rendered element results in
+	// +kubebuilder:validation:Enum=string;int;bool;object
+	// +optional
+	ElementType string `json:"elementType,omitempty"`
+
+	// ObjectList specifies a kind and label selector to list objects. Each matching object results in one matrix
+	// input. The service account used by the ObjectTemplate must have proper permissions to list these objects
+	// +optional
+	ObjectList *MatrixEntryObjectList `json:"objectList,omitempty"`
+
+	// Namespaces lists namespaces, optionally filtered by a label selector. Each matching namespace results in one
+	// matrix input. Namespaces are listed with the permissions of the controller, so the service account used by the
+	// ObjectTemplate does not need permissions to list namespaces
+	// +optional
+	Namespaces *MatrixEntryNamespaces `json:"namespaces,omitempty"`
+
+	// ConfigMap specifies a ConfigMap key to load and parse as YAML/JSON. The parsed value is made available while
+	// rendering templates. The service account used by the ObjectTemplate must have proper permissions to get this
+	// ConfigMap
+	// +optional
+	ConfigMap *MatrixEntryConfigMap `json:"configMap,omitempty"`
+
+	// Secret specifies a Secret key to load, decode and parse as YAML/JSON. The parsed value is made available while
+	// rendering templates. Values loaded from Secrets are scrubbed from error messages. The service account used by
+	// the ObjectTemplate must have proper permissions to get this Secret
+	// +optional
+	Secret *MatrixEntrySecret `json:"secret,omitempty"`
+
+	// HTTP specifies an HTTP endpoint that returns JSON. The parsed response is made available while rendering
+	// templates
+	// +optional
+	HTTP *MatrixEntryHTTP `json:"http,omitempty"`
+
+	// Range specifies a sequence of integers. Each number results in one matrix input
+	// +optional
+	Range *MatrixEntryRange `json:"range,omitempty"`
+
+	// Git specifies a Git repository and a glob of YAML files to load. Each YAML document found in the matching files
+	// results in one matrix input
+	// +optional
+	Git *MatrixEntryGit `json:"git,omitempty"`
+
+	// ObjectTemplate specifies another ObjectTemplate whose status is used as input. By default, each successfully
+	// applied resource of the referenced ObjectTemplate results in one matrix input. Changes to the status of the
+	// referenced ObjectTemplate trigger a reconciliation
+	// +optional
+	ObjectTemplate *MatrixEntryObjectTemplate `json:"objectTemplate,omitempty"`
 }
 
 type MatrixEntryObject struct {
@@ -86,6 +480,90 @@ type MatrixEntryObject struct {
 	// +optional
 	JsonPath *string `json:"jsonPath,omitempty"`
 
+	// Engine specifies the expression language used for JsonPath. Can be `jsonpath` or `jmespath`.
+	// +kubebuilder:validation:Enum=jsonpath;jmespath
+	// +kubebuilder:default:="jsonpath"
+	// +optional
+	Engine string `json:"engine,omitempty"`
+
+	// ExpandLists enables optional expanding of list. Expanding means, that each list entry is interpreted as
+	// individual matrix input instead of interpreting the whole list as one matrix input. This feature is only useful
+	// when used in combination with `jsonPath`
+	// +optional
+	ExpandLists bool `json:"expandLists,omitempty"`
+
+	// FailOnEmpty causes reconciliation to fail when the JsonPath does not return any results, instead of silently
+	// resulting in an empty matrix
+	// +optional
+	FailOnEmpty bool `json:"failOnEmpty,omitempty"`
+
+	// IncludeKeys enables expanding of maps. Each entry of a map is then interpreted as individual matrix input of the
+	// form `{key: ..., value: ...}`, sorted by key. Lists and scalar values are not affected
+	// +optional
+	IncludeKeys bool `json:"includeKeys,omitempty"`
+
+	// Fields optionally specifies a map of field names to expressions (using the same engine as JsonPath). When
+	// specified, each matrix input is turned into an object that contains one field per entry, with the value being
+	// the result of evaluating the expression against the matrix input
+	// +optional
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// IsTemplated returns true if the namespace, name or jsonPath contain Jinja2 expressions. Such entries are rendered
+// once per partial matrix row, with access to the values of all previous matrix entries
+func (me *MatrixEntryObject) IsTemplated() bool {
+	return IsTemplatedString(me.Ref.Namespace) || IsTemplatedString(me.Ref.Name) || (me.JsonPath != nil && IsTemplatedString(*me.JsonPath))
+}
+
+// IsTemplatedString returns true if s contains Jinja2 expressions or statements
+func IsTemplatedString(s string) bool {
+	return strings.Contains(s, "{{") || strings.Contains(s, "{%")
+}
+
+const (
+	PathEngineJsonPath = "jsonpath"
+	PathEngineJmesPath = "jmespath"
+)
+
+const (
+	ElementTypeString = "string"
+	ElementTypeInt    = "int"
+	ElementTypeBool   = "bool"
+	ElementTypeObject = "object"
+)
+
+type MatrixEntryObjectList struct {
+	// APIVersion specifies the apiVersion of the objects to list
+	// +required
+	APIVersion string `json:"apiVersion"`
+
+	// Kind specifies the kind of the objects to list
+	// +required
+	Kind string `json:"kind"`
+
+	// Namespace specifies the namespace to list objects in. If omitted, the namespace of the ObjectTemplate is used
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// LabelSelector optionally specifies a label selector to filter the listed objects. If omitted, all objects of
+	// the given kind in the namespace are listed
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// ExcludeDeleting excludes listed objects that are being deleted, meaning that their deletionTimestamp is set
+	// +optional
+	ExcludeDeleting bool `json:"excludeDeleting,omitempty"`
+
+	// ExcludeWhen optionally specifies a Jinja2 expression that is evaluated for each listed object, with the object
+	// being available as `object`. Objects for which the expression evaluates to a truthy value are excluded
+	// +optional
+	ExcludeWhen string `json:"excludeWhen,omitempty"`
+
+	// JsonPath optionally specifies a sub-field to load from each listed object. When specified, the sub-field (and
+	// not the whole object) is made available while rendering templates
+	// +optional
+	JsonPath *string `json:"jsonPath,omitempty"`
+
 	// ExpandLists enables optional expanding of list. Expanding means, that each list entry is interpreted as
 	// individual matrix input instead of interpreting the whole list as one matrix input. This feature is only useful
 	// when used in combination with `jsonPath`
@@ -93,7 +571,157 @@ type MatrixEntryObject struct {
 	ExpandLists bool `json:"expandLists,omitempty"`
 }
 
+func (m *MatrixEntryObjectList) GroupVersionKind() (schema.GroupVersionKind, error) {
+	ref := ObjectRef{APIVersion: m.APIVersion, Kind: m.Kind}
+	return ref.GroupVersionKind()
+}
+
+type MatrixEntryNamespaces struct {
+	// LabelSelector optionally specifies a label selector to filter the listed namespaces. If omitted, all namespaces
+	// are listed
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+type MatrixEntryObjectTemplate struct {
+	// Ref specifies the name and optionally the namespace of the ObjectTemplate. If the namespace is omitted, the
+	// namespace of the ObjectTemplate is used
+	// +required
+	Ref NamespacedObjectReference `json:"ref"`
+
+	// JsonPath optionally specifies a sub-field of the referenced ObjectTemplate to load instead of the refs of the
+	// applied resources, e.g. `status.appliedResources`
+	// +optional
+	JsonPath *string `json:"jsonPath,omitempty"`
+
+	// ExpandLists enables optional expanding of list. This is only useful when used in combination with `jsonPath`
+	// +optional
+	ExpandLists bool `json:"expandLists,omitempty"`
+}
+
+type MatrixEntryConfigMap struct {
+	// Ref specifies the name and optionally the namespace of the ConfigMap to load. If the namespace is omitted, the
+	// namespace of the ObjectTemplate is used
+	// +required
+	Ref NamespacedObjectReference `json:"ref"`
+
+	// Key specifies the data key of the ConfigMap to load. The value is parsed as YAML/JSON
+	// +required
+	Key string `json:"key"`
+
+	// ExpandLists enables optional expanding of list. Expanding means, that each list entry is interpreted as
+	// individual matrix input instead of interpreting the whole list as one matrix input. This is only useful when
+	// the value stored in the ConfigMap key is a list
+	// +optional
+	ExpandLists bool `json:"expandLists,omitempty"`
+}
+
+type MatrixEntrySecret struct {
+	// Ref specifies the name and optionally the namespace of the Secret to load. If the namespace is omitted, the
+	// namespace of the ObjectTemplate is used
+	// +required
+	Ref NamespacedObjectReference `json:"ref"`
+
+	// Key specifies the data key of the Secret to load. The decoded value is parsed as YAML/JSON
+	// +required
+	Key string `json:"key"`
+
+	// ExpandLists enables optional expanding of list. Expanding means, that each list entry is interpreted as
+	// individual matrix input instead of interpreting the whole list as one matrix input. This is only useful when
+	// the value stored in the Secret key is a list
+	// +optional
+	ExpandLists bool `json:"expandLists,omitempty"`
+}
+
+type MatrixEntryRange struct {
+	// Start specifies the first number of the sequence.
+	// +kubebuilder:default:=0
+	// +optional
+	Start int64 `json:"start,omitempty"`
+
+	// Stop specifies the end of the sequence. The sequence does not include this number.
+	// +required
+	Stop int64 `json:"stop"`
+
+	// Step specifies the difference between two numbers of the sequence. Negative steps produce descending sequences.
+	// +kubebuilder:default:=1
+	// +optional
+	Step int64 `json:"step,omitempty"`
+}
+
+type MatrixEntryHTTP struct {
+	// URL specifies the URL to send the GET request to. The response must be JSON
+	// +required
+	URL string `json:"url"`
+
+	// HeadersSecretRef optionally refers a Secret in the same namespace as the ObjectTemplate. Each key of the Secret
+	// is sent as header, with the value of the key being the header value
+	// +optional
+	HeadersSecretRef *LocalObjectReference `json:"headersSecretRef,omitempty"`
+
+	// JsonPath optionally specifies a sub-field to load. When specified, the sub-field (and not the whole response)
+	// is made available while rendering templates
+	// +optional
+	JsonPath *string `json:"jsonPath,omitempty"`
+
+	// ExpandLists enables optional expanding of list. Expanding means, that each list entry is interpreted as
+	// individual matrix input instead of interpreting the whole list as one matrix input
+	// +optional
+	ExpandLists bool `json:"expandLists,omitempty"`
+
+	// Timeout specifies the timeout of the HTTP request
+	// +kubebuilder:default:="10s"
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+type MatrixEntryGit struct {
+	// URL specifies the Git url to clone
+	// +required
+	URL string `json:"url"`
+
+	// Reference specifies the Git branch, tag or commit to load the files from. Branches and tags can contain regular
+	// expressions, but must match exactly one ref. If omitted, the default branch is used
+	// +optional
+	Reference *GitRef `json:"ref,omitempty"`
+
+	// Path specifies a glob that is matched against the paths of all files in the repository
+	// +required
+	Path string `json:"path"`
+
+	// SecretRef specifies a Secret in the same namespace as the ObjectTemplate that is used for Git authentication.
+	// The contents of the secret must conform to:
+	// https://kluctl.io/docs/flux/spec/v1alpha1/kluctldeployment/#git-authentication
+	// +optional
+	SecretRef *LocalObjectReference `json:"secretRef,omitempty"`
+
+	// ExpandLists enables optional expanding of list. Expanding means, that each list entry is interpreted as
+	// individual matrix input instead of interpreting the whole list as one matrix input
+	// +optional
+	ExpandLists bool `json:"expandLists,omitempty"`
+}
+
 type Template struct {
+	// When specifies an optional Jinja2 expression that is evaluated against the same variables that are used while
+	// rendering. If the expression evaluates to a falsy value, the template is skipped for the current matrix entry
+	// +optional
+	When string `json:"when,omitempty"`
+
+	// Namespace specifies an optional Jinja2 expression that is evaluated against the same variables that are used
+	// while rendering. The result is used as namespace for all namespaced objects rendered by this template that don't
+	// specify a namespace themselves. If omitted, the namespace of the ObjectTemplate is used
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ExistingRef optionally refers an object that is fetched from the target cluster before the template is rendered.
+	// The live object is made available to the template via the `existing` variable, which is None if the object
+	// does not exist. The namespace and name are rendered with the same variables as the template itself. If the
+	// namespace is omitted, the namespace of the template is used
+	// +optional
+	ExistingRef *ObjectRef `json:"existingRef,omitempty"`
+
 	// Object specifies a structured object in YAML form. Each field value is rendered independently.
 	// +optional
 	// +kubebuilder:pruning:PreserveUnknownFields
@@ -104,6 +732,60 @@ type Template struct {
 	// interpreted as a string (which would be done in Object).
 	// +optional
 	Raw *string `json:"raw,omitempty"`
+
+	// ApplyMode specifies how the rendered objects are applied. `apply` uses server-side apply and creates the object
+	// if needed. `merge` sends the rendered object as strategic merge patch (or JSON merge patch for custom resources)
+	// and `jsonPatch` sends a JSON patch computed from the difference between the existing object and the rendered
+	// object. Both patch modes require the object to already exist and objects applied with these modes are never
+	// pruned. `createOnly` creates the object if it does not exist yet and never updates it afterwards. Objects
+	// applied with `createOnly` are only pruned if they were created by the ObjectTemplate. `onCreate` renders and
+	// applies the template (via server-side apply) only until the first reconciliation succeeded, e.g. for one-time
+	// migration Jobs. Objects applied with `onCreate` are left alone afterwards and are never pruned
+	// +kubebuilder:validation:Enum=apply;merge;jsonPatch;createOnly;onCreate
+	// +kubebuilder:default:="apply"
+	// +optional
+	ApplyMode string `json:"applyMode,omitempty"`
+
+	// Format specifies the format of the rendered output of raw and configMap templates. Can be `yaml` (the default)
+	// or `json`. JSON output can contain multiple objects, either as a top-level array or as a stream of objects
+	// +kubebuilder:validation:Enum=yaml;json
+	// +kubebuilder:default:="yaml"
+	// +optional
+	Format string `json:"format,omitempty"`
+
+	// ConfigMap specifies a ConfigMap in the same namespace as the ObjectTemplate that contains raw templates. Each
+	// key (or only the selected key) is rendered and parsed the same way as Raw.
+	// +optional
+	ConfigMap *TemplateConfigMapRef `json:"configMap,omitempty"`
+}
+
+const (
+	TemplateFormatYaml = "yaml"
+	TemplateFormatJson = "json"
+)
+
+const (
+	ApplyModeApply      = "apply"
+	ApplyModeMerge      = "merge"
+	ApplyModeJsonPatch  = "jsonPatch"
+	ApplyModeCreateOnly = "createOnly"
+	ApplyModeOnCreate   = "onCreate"
+)
+
+const (
+	ConflictPolicyFail   = "fail"
+	ConflictPolicyForce  = "force"
+	ConflictPolicyIgnore = "ignore"
+)
+
+type TemplateConfigMapRef struct {
+	// Name specifies the name of the ConfigMap.
+	// +required
+	Name string `json:"name"`
+
+	// Key specifies the key to render. If omitted, all keys are rendered in alphabetical order.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // ObjectTemplateStatus defines the observed state of ObjectTemplate
@@ -113,6 +795,83 @@ type ObjectTemplateStatus struct {
 
 	// +optional
 	AppliedResources []AppliedResourceInfo `json:"appliedResources,omitempty"`
+
+	// FailureCount is the number of consecutive failed reconciliations. It is used to calculate the backoff until the
+	// next reconciliation and is reset after a successful reconciliation
+	// +optional
+	FailureCount int `json:"failureCount,omitempty"`
+
+	// OrphanedResources contains the objects that were previously applied but are not rendered anymore and were not
+	// deleted. This happens when pruning is disabled, when the object was never applied successfully, as it might not
+	// have been created by the ObjectTemplate, or when pruning is disabled via annotation on the object
+	// +optional
+	OrphanedResources []ObjectRef `json:"orphanedResources,omitempty"`
+
+	// InitialApplySucceeded is set after the first reconciliation that applied all rendered objects successfully.
+	// Templates with the `onCreate` apply mode are not rendered anymore afterwards
+	// +optional
+	InitialApplySucceeded bool `json:"initialApplySucceeded,omitempty"`
+
+	// Warnings contains non-fatal problems found in the last reconciliation, e.g. missing optional matrix inputs
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+
+	// MatrixCount is the number of matrix entries produced in the last reconciliation, before matrixFilter was applied
+	// +optional
+	MatrixCount int `json:"matrixCount,omitempty"`
+
+	// RenderedResourceCount is the number of objects rendered in the last reconciliation
+	// +optional
+	RenderedResourceCount int `json:"renderedResourceCount,omitempty"`
+
+	// DriftedResources contains the objects that differed from the rendered objects before they were applied. Only
+	// filled when drift detection is enabled
+	// +optional
+	DriftedResources []DriftedResource `json:"driftedResources,omitempty"`
+
+	// DryRunResults contains the results of the last reconciliation in dry-run mode
+	// +optional
+	DryRunResults []DryRunResult `json:"dryRunResults,omitempty"`
+
+	// LastReconcileTime is the time of the last reconciliation attempt
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// LastSuccessfulReconcileTime is the time of the last successful reconciliation
+	// +optional
+	LastSuccessfulReconcileTime *metav1.Time `json:"lastSuccessfulReconcileTime,omitempty"`
+
+	// ObservedSources contains the inputs of the last successful reconciliation. Only set when skipUnchangedSources is
+	// enabled and supported by the ObjectTemplate
+	// +optional
+	ObservedSources *ObservedSources `json:"observedSources,omitempty"`
+
+	// LastHandledReconcileAt is the value of the reconcile request annotation that was handled by the last
+	// reconciliation
+	// +optional
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+}
+
+// ObservedSources describes the inputs of the last successful reconciliation
+type ObservedSources struct {
+	// Generation is the generation of the ObjectTemplate
+	Generation int64 `json:"generation"`
+
+	// MetadataHash is the hash of the labels and annotations of the ObjectTemplate and the controller version
+	// +optional
+	MetadataHash string `json:"metadataHash,omitempty"`
+
+	// Objects contains the resourceVersions of all objects the ObjectTemplate reads from
+	// +optional
+	Objects []ObservedSourceObject `json:"objects,omitempty"`
+}
+
+type ObservedSourceObject struct {
+	Ref ObjectRef `json:"ref"`
+
+	// ResourceVersion is the resourceVersion of the object, or empty if it did not exist
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
 }
 
 type AppliedResourceInfo struct {
@@ -120,8 +879,88 @@ type AppliedResourceInfo struct {
 
 	Success bool `json:"success"`
 
+	// ForceApplied is true if the object was applied with forced ownership
+	// +optional
+	ForceApplied bool `json:"forceApplied,omitempty"`
+
+	// ConflictPolicy is the conflict policy that was used to apply the object. It is `force` if ownership was forced
+	// and `ignore` if conflicting fields were left out. It is empty if the object was applied without conflict handling
+	// +optional
+	ConflictPolicy string `json:"conflictPolicy,omitempty"`
+
+	// IgnoredFields contains the fields that were left out of the applied object due to conflicts with other field
+	// managers. Only filled when the `ignore` conflict policy is used
+	// +optional
+	IgnoredFields []string `json:"ignoredFields,omitempty"`
+
+	// ApplyMode is the mode that was used to apply the object. Objects that were patched (instead of applied) are not
+	// pruned
+	// +optional
+	ApplyMode string `json:"applyMode,omitempty"`
+
+	// Created is true if the object was created by the ObjectTemplate. It is only tracked for objects applied with
+	// the `createOnly` mode, where false means that the object already existed and was left untouched
+	// +optional
+	Created bool `json:"created,omitempty"`
+
 	// +optional
 	Error string `json:"error,omitempty"`
+
+	// LastAppliedTime is the time of the last successful apply of the object. It is kept when applying fails
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// Generation is the metadata.generation of the object as returned by the last successful apply
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// ResourceVersion is the metadata.resourceVersion of the object as returned by the last successful apply
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// Hash is the hash of the rendered object as of the last successful apply. Applying is skipped when neither the
+	// hash nor the resourceVersion of the live object changed
+	// +optional
+	Hash string `json:"hash,omitempty"`
+
+	// PruneCandidateSince is the time since when the object is not rendered anymore. It is only set while the object
+	// waits for spec.pruneGracePeriod to pass before being pruned
+	// +optional
+	PruneCandidateSince *metav1.Time `json:"pruneCandidateSince,omitempty"`
+
+	// Health is the kstatus of the object, only set when waiting is enabled.
+	// +optional
+	Health string `json:"health,omitempty"`
+}
+
+const (
+	DryRunActionCreate    = "Create"
+	DryRunActionUpdate    = "Update"
+	DryRunActionUnchanged = "Unchanged"
+	DryRunActionPrune     = "Prune"
+)
+
+type DryRunResult struct {
+	Ref ObjectRef `json:"ref"`
+
+	// Action specifies what would have happened to the object. Can be Create, Update, Unchanged or Prune
+	Action string `json:"action"`
+
+	// ChangedFields contains the JSON paths of the fields that would have been changed by an update
+	// +optional
+	ChangedFields []string `json:"changedFields,omitempty"`
+}
+
+type DriftedResource struct {
+	Ref ObjectRef `json:"ref"`
+
+	// Deleted is true if the object was previously applied but does not exist anymore
+	// +optional
+	Deleted bool `json:"deleted,omitempty"`
+
+	// ChangedFields contains the JSON paths of the fields that differ between the live and the rendered object
+	// +optional
+	ChangedFields []string `json:"changedFields,omitempty"`
 }
 
 // GetConditions returns the status conditions of the object.
@@ -136,6 +975,12 @@ func (in *ObjectTemplate) SetConditions(conditions []metav1.Condition) {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Matrix",type="integer",JSONPath=".status.matrixCount"
+//+kubebuilder:printcolumn:name="Resources",type="integer",JSONPath=".status.renderedResourceCount"
+//+kubebuilder:printcolumn:name="Last Reconcile",type="date",JSONPath=".status.lastReconcileTime"
+//+kubebuilder:printcolumn:name="Last Success",type="date",JSONPath=".status.lastSuccessfulReconcileTime"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ObjectTemplate is the Schema for the objecttemplates API
 type ObjectTemplate struct {
@@ -0,0 +1,309 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"github.com/jmespath/go-jmespath"
+	"github.com/ohler55/ojg/jp"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func (r *ObjectTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-templates-kluctl-io-v1alpha1-objecttemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=templates.kluctl.io,resources=objecttemplates,verbs=create;update,versions=v1alpha1,name=vobjecttemplate.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ObjectTemplate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectTemplate) ValidateCreate() (admission.Warnings, error) {
+	return nil, r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectTemplate) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	// objects that were valid under older rules must still be able to remove their finalizer or change metadata
+	if r.GetDeletionTimestamp() != nil {
+		return nil, nil
+	}
+	if o, ok := old.(*ObjectTemplate); ok && equality.Semantic.DeepEqual(o.Spec, r.Spec) {
+		return nil, nil
+	}
+	return nil, r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectTemplate) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (r *ObjectTemplate) validate() error {
+	errs := r.Spec.Validate(field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("ObjectTemplate").GroupKind(), r.Name, errs)
+}
+
+// Validate performs validation that can not be expressed via the OpenAPI schema
+func (s *ObjectTemplateSpec) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	errs = append(errs, ValidateMatrix(s.Matrix, fldPath.Child("matrix"))...)
+
+	for i, vs := range s.Vars {
+		p := fldPath.Child("vars").Index(i)
+		if vs == nil {
+			errs = append(errs, field.Required(p, "vars entry must not be null"))
+			continue
+		}
+		cnt := 0
+		if vs.Values != nil {
+			cnt++
+		}
+		if vs.ConfigMap != nil {
+			cnt++
+		}
+		if vs.Secret != nil {
+			cnt++
+		}
+		if cnt != 1 {
+			errs = append(errs, field.Invalid(p, cnt, "exactly one of values, configMap or secret must be specified"))
+		}
+	}
+
+	fieldManagerKinds := make([]string, 0, len(s.FieldManagerByKind))
+	for k := range s.FieldManagerByKind {
+		fieldManagerKinds = append(fieldManagerKinds, k)
+	}
+	sort.Strings(fieldManagerKinds)
+	for _, k := range fieldManagerKinds {
+		p := fldPath.Child("fieldManagerByKind").Key(k)
+		gk := schema.ParseGroupKind(k)
+		if gk.Kind == "" || strings.IndexFunc(k, unicode.IsSpace) != -1 {
+			errs = append(errs, field.Invalid(p, k, "key must be in the form <kind>.<group> or <kind> for the core group"))
+		}
+		fm := s.FieldManagerByKind[k]
+		if fm == "" || len(fm) > 128 || strings.IndexFunc(fm, unicode.IsSpace) != -1 {
+			errs = append(errs, field.Invalid(p, fm, "field manager must be non-empty, must not contain whitespace and must be at most 128 characters"))
+		}
+	}
+
+	if s.ForceApply && s.ConflictPolicy != "" && s.ConflictPolicy != ConflictPolicyForce {
+		errs = append(errs, field.Invalid(fldPath.Child("conflictPolicy"), s.ConflictPolicy, "forceApply can only be combined with the force conflict policy"))
+	}
+
+	for i, ef := range s.ExcludeFields {
+		p := fldPath.Child("excludeFields").Index(i)
+		if ef == nil {
+			errs = append(errs, field.Required(p, "excludeFields entry must not be null"))
+			continue
+		}
+		if len(ef.JsonPaths) == 0 {
+			errs = append(errs, field.Required(p.Child("jsonPaths"), "at least one jsonPath must be specified"))
+		}
+		for j, jsonPath := range ef.JsonPaths {
+			jsonPath := jsonPath
+			errs = append(errs, validateJsonPath(p.Child("jsonPaths").Index(j), &jsonPath)...)
+		}
+	}
+
+	healthCheckKinds := map[schema.GroupKind]bool{}
+	for i, hc := range s.HealthChecks {
+		p := fldPath.Child("healthChecks").Index(i)
+		if hc.Kind == "" {
+			errs = append(errs, field.Required(p.Child("kind"), "kind must be specified"))
+		}
+		if hc.Ready == "" {
+			errs = append(errs, field.Required(p.Child("ready"), "ready expression must be specified"))
+		}
+		gk := schema.GroupKind{Group: hc.Group, Kind: hc.Kind}
+		if healthCheckKinds[gk] {
+			errs = append(errs, field.Duplicate(p, gk.String()))
+		}
+		healthCheckKinds[gk] = true
+	}
+
+	if s.TargetNamespace != "" {
+		for _, msg := range apivalidation.ValidateNamespaceName(s.TargetNamespace, false) {
+			errs = append(errs, field.Invalid(fldPath.Child("targetNamespace"), s.TargetNamespace, msg))
+		}
+	}
+
+	if s.PruneGracePeriod != nil && s.PruneGracePeriod.Duration < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("pruneGracePeriod"), s.PruneGracePeriod.Duration.String(), "must not be negative"))
+	}
+
+	if s.CommonMetadata != nil {
+		p := fldPath.Child("commonMetadata")
+		errs = append(errs, metav1validation.ValidateLabels(s.CommonMetadata.Labels, p.Child("labels"))...)
+		errs = append(errs, apivalidation.ValidateAnnotations(s.CommonMetadata.Annotations, p.Child("annotations"))...)
+	}
+
+	for i, t := range s.Templates {
+		p := fldPath.Child("templates").Index(i)
+		cnt := 0
+		if t.Object != nil {
+			cnt++
+		}
+		if t.Raw != nil {
+			cnt++
+		}
+		if t.ConfigMap != nil {
+			cnt++
+		}
+		if cnt != 1 {
+			errs = append(errs, field.Invalid(p, cnt, "exactly one of object, raw or configMap must be specified"))
+		}
+	}
+
+	return errs
+}
+
+// ValidateMatrix validates all matrix entries and ensures that the names of the entries are non-empty and unique, as
+// entries with the same name would silently overwrite each other in the multiplied matrix
+func ValidateMatrix(matrix []*MatrixEntry, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	names := map[string]bool{}
+	for i, me := range matrix {
+		p := fldPath.Index(i)
+		if me == nil {
+			errs = append(errs, field.Required(p, "matrix entry must not be null"))
+			continue
+		}
+		if me.Name == "" {
+			errs = append(errs, field.Required(p.Child("name"), "matrix entry name must not be empty"))
+		} else if names[me.Name] {
+			errs = append(errs, field.Duplicate(p.Child("name"), me.Name))
+		}
+		names[me.Name] = true
+		errs = append(errs, me.validate(p)...)
+	}
+	return errs
+}
+
+func (me *MatrixEntry) validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	cnt := 0
+	if me.Object != nil {
+		cnt++
+		if me.Object.IsTemplated() {
+			// expressions can only be validated after rendering
+		} else if me.Object.Engine == PathEngineJmesPath {
+			errs = append(errs, validateJmesPath(fldPath.Child("object", "jsonPath"), me.Object.JsonPath)...)
+		} else {
+			errs = append(errs, validateJsonPath(fldPath.Child("object", "jsonPath"), me.Object.JsonPath)...)
+		}
+		for name, expr := range me.Object.Fields {
+			expr := expr
+			if me.Object.Engine == PathEngineJmesPath {
+				errs = append(errs, validateJmesPath(fldPath.Child("object", "fields").Key(name), &expr)...)
+			} else {
+				errs = append(errs, validateJsonPath(fldPath.Child("object", "fields").Key(name), &expr)...)
+			}
+		}
+	}
+	if me.List != nil {
+		cnt++
+	}
+	if me.ObjectList != nil {
+		cnt++
+		errs = append(errs, validateJsonPath(fldPath.Child("objectList", "jsonPath"), me.ObjectList.JsonPath)...)
+	}
+	if me.Namespaces != nil {
+		cnt++
+	}
+	if me.ConfigMap != nil {
+		cnt++
+	}
+	if me.Secret != nil {
+		cnt++
+	}
+	if me.HTTP != nil {
+		cnt++
+		errs = append(errs, validateJsonPath(fldPath.Child("http", "jsonPath"), me.HTTP.JsonPath)...)
+	}
+	if me.Range != nil {
+		cnt++
+		if me.Range.Step == 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("range", "step"), me.Range.Step, "step must not be 0"))
+		}
+	}
+	if me.Git != nil {
+		cnt++
+		if me.Git.Path == "" {
+			errs = append(errs, field.Required(fldPath.Child("git", "path"), "path glob must be specified"))
+		}
+	}
+	if me.ObjectTemplate != nil {
+		cnt++
+		errs = append(errs, validateJsonPath(fldPath.Child("objectTemplate", "jsonPath"), me.ObjectTemplate.JsonPath)...)
+	}
+	if cnt != 1 {
+		errs = append(errs, field.Invalid(fldPath, cnt, "exactly one matrix source must be specified"))
+	}
+	if me.ElementType != "" && me.List == nil {
+		errs = append(errs, field.Invalid(fldPath.Child("elementType"), me.ElementType, "elementType can only be used with list"))
+	}
+	if me.Defaults != nil {
+		var m map[string]any
+		if err := json.Unmarshal(me.Defaults.Raw, &m); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("defaults"), string(me.Defaults.Raw), "defaults must be a map"))
+		}
+	}
+	return errs
+}
+
+func validateJsonPath(fldPath *field.Path, jsonPath *string) field.ErrorList {
+	if jsonPath == nil {
+		return nil
+	}
+	_, err := jp.ParseString(*jsonPath)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, *jsonPath, err.Error())}
+	}
+	return nil
+}
+
+func validateJmesPath(fldPath *field.Path, expr *string) field.ErrorList {
+	if expr == nil {
+		return nil
+	}
+	_, err := jmespath.Compile(*expr)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, *expr, err.Error())}
+	}
+	return nil
+}
@@ -12,6 +12,16 @@ type LocalObjectReference struct {
 	Name string `json:"name"`
 }
 
+type NamespacedObjectReference struct {
+	// Name of the referent.
+	// +required
+	Name string `json:"name"`
+
+	// Namespace of the referent. Defaults to the namespace of the referring object.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
 // Utility struct for a reference to a secret key.
 type SecretRef struct {
 	SecretName string `json:"secretName"`
@@ -55,6 +65,20 @@ func (r *ObjectRef) GroupVersionKind() (schema.GroupVersionKind, error) {
 	}, nil
 }
 
+// Less defines a stable order of refs, sorting by apiVersion, kind, namespace and name
+func (r *ObjectRef) Less(o ObjectRef) bool {
+	if r.APIVersion != o.APIVersion {
+		return r.APIVersion < o.APIVersion
+	}
+	if r.Kind != o.Kind {
+		return r.Kind < o.Kind
+	}
+	if r.Namespace != o.Namespace {
+		return r.Namespace < o.Namespace
+	}
+	return r.Name < o.Name
+}
+
 func (r *ObjectRef) WithoutVersion() ObjectRef {
 	gv, err := schema.ParseGroupVersion(r.APIVersion)
 	if err != nil {
@@ -31,6 +31,19 @@ import (
 func (in *AppliedResourceInfo) DeepCopyInto(out *AppliedResourceInfo) {
 	*out = *in
 	out.Ref = in.Ref
+	if in.IgnoredFields != nil {
+		in, out := &in.IgnoredFields, &out.IgnoredFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastAppliedTime != nil {
+		in, out := &in.LastAppliedTime, &out.LastAppliedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PruneCandidateSince != nil {
+		in, out := &in.PruneCandidateSince, &out.PruneCandidateSince
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppliedResourceInfo.
@@ -94,6 +107,35 @@ func (in *CommentSpec) DeepCopy() *CommentSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommonMetadata) DeepCopyInto(out *CommonMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommonMetadata.
+func (in *CommonMetadata) DeepCopy() *CommonMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(CommonMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigMapRef) DeepCopyInto(out *ConfigMapRef) {
 	*out = *in
@@ -109,6 +151,73 @@ func (in *ConfigMapRef) DeepCopy() *ConfigMapRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftedResource) DeepCopyInto(out *DriftedResource) {
+	*out = *in
+	out.Ref = in.Ref
+	if in.ChangedFields != nil {
+		in, out := &in.ChangedFields, &out.ChangedFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftedResource.
+func (in *DriftedResource) DeepCopy() *DriftedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunResult) DeepCopyInto(out *DryRunResult) {
+	*out = *in
+	out.Ref = in.Ref
+	if in.ChangedFields != nil {
+		in, out := &in.ChangedFields, &out.ChangedFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunResult.
+func (in *DryRunResult) DeepCopy() *DryRunResult {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExcludeFields) DeepCopyInto(out *ExcludeFields) {
+	*out = *in
+	if in.Group != nil {
+		in, out := &in.Group, &out.Group
+		*out = new(string)
+		**out = **in
+	}
+	if in.JsonPaths != nil {
+		in, out := &in.JsonPaths, &out.JsonPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExcludeFields.
+func (in *ExcludeFields) DeepCopy() *ExcludeFields {
+	if in == nil {
+		return nil
+	}
+	out := new(ExcludeFields)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitFile) DeepCopyInto(out *GitFile) {
 	*out = *in
@@ -660,6 +769,52 @@ func (in *HandlerStatus) DeepCopy() *HandlerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheck) DeepCopyInto(out *HealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheck.
+func (in *HealthCheck) DeepCopy() *HealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeConfig) DeepCopyInto(out *KubeConfig) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfig.
+func (in *KubeConfig) DeepCopy() *KubeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeConfigSecretRef) DeepCopyInto(out *KubeConfigSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfigSecretRef.
+func (in *KubeConfigSecretRef) DeepCopy() *KubeConfigSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeConfigSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ListGithubPullRequests) DeepCopyInto(out *ListGithubPullRequests) {
 	*out = *in
@@ -923,6 +1078,11 @@ func (in *LocalObjectReference) DeepCopy() *LocalObjectReference {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MatrixEntry) DeepCopyInto(out *MatrixEntry) {
 	*out = *in
+	if in.Defaults != nil {
+		in, out := &in.Defaults, &out.Defaults
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Object != nil {
 		in, out := &in.Object, &out.Object
 		*out = new(MatrixEntryObject)
@@ -935,6 +1095,46 @@ func (in *MatrixEntry) DeepCopyInto(out *MatrixEntry) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ObjectList != nil {
+		in, out := &in.ObjectList, &out.ObjectList
+		*out = new(MatrixEntryObjectList)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = new(MatrixEntryNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(MatrixEntryConfigMap)
+		**out = **in
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(MatrixEntrySecret)
+		**out = **in
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(MatrixEntryHTTP)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Range != nil {
+		in, out := &in.Range, &out.Range
+		*out = new(MatrixEntryRange)
+		**out = **in
+	}
+	if in.Git != nil {
+		in, out := &in.Git, &out.Git
+		*out = new(MatrixEntryGit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObjectTemplate != nil {
+		in, out := &in.ObjectTemplate, &out.ObjectTemplate
+		*out = new(MatrixEntryObjectTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntry.
@@ -948,184 +1148,541 @@ func (in *MatrixEntry) DeepCopy() *MatrixEntry {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MatrixEntryObject) DeepCopyInto(out *MatrixEntryObject) {
+func (in *MatrixEntryConfigMap) DeepCopyInto(out *MatrixEntryConfigMap) {
 	*out = *in
 	out.Ref = in.Ref
-	if in.JsonPath != nil {
-		in, out := &in.JsonPath, &out.JsonPath
-		*out = new(string)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryObject.
-func (in *MatrixEntryObject) DeepCopy() *MatrixEntryObject {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryConfigMap.
+func (in *MatrixEntryConfigMap) DeepCopy() *MatrixEntryConfigMap {
 	if in == nil {
 		return nil
 	}
-	out := new(MatrixEntryObject)
+	out := new(MatrixEntryConfigMap)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectHandler) DeepCopyInto(out *ObjectHandler) {
+func (in *MatrixEntryGit) DeepCopyInto(out *MatrixEntryGit) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.Reference != nil {
+		in, out := &in.Reference, &out.Reference
+		*out = new(GitRef)
+		**out = **in
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandler.
-func (in *ObjectHandler) DeepCopy() *ObjectHandler {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryGit.
+func (in *MatrixEntryGit) DeepCopy() *MatrixEntryGit {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectHandler)
+	out := new(MatrixEntryGit)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ObjectHandler) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectHandlerList) DeepCopyInto(out *ObjectHandlerList) {
+func (in *MatrixEntryHTTP) DeepCopyInto(out *MatrixEntryHTTP) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]ObjectHandler, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.HeadersSecretRef != nil {
+		in, out := &in.HeadersSecretRef, &out.HeadersSecretRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
+	if in.JsonPath != nil {
+		in, out := &in.JsonPath, &out.JsonPath
+		*out = new(string)
+		**out = **in
 	}
+	out.Timeout = in.Timeout
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandlerList.
-func (in *ObjectHandlerList) DeepCopy() *ObjectHandlerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryHTTP.
+func (in *MatrixEntryHTTP) DeepCopy() *MatrixEntryHTTP {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectHandlerList)
+	out := new(MatrixEntryHTTP)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ObjectHandlerList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectHandlerSpec) DeepCopyInto(out *ObjectHandlerSpec) {
+func (in *MatrixEntryNamespaces) DeepCopyInto(out *MatrixEntryNamespaces) {
 	*out = *in
-	out.Interval = in.Interval
-	out.ForObject = in.ForObject
-	if in.Handlers != nil {
-		in, out := &in.Handlers, &out.Handlers
-		*out = make([]Handler, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandlerSpec.
-func (in *ObjectHandlerSpec) DeepCopy() *ObjectHandlerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryNamespaces.
+func (in *MatrixEntryNamespaces) DeepCopy() *MatrixEntryNamespaces {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectHandlerSpec)
+	out := new(MatrixEntryNamespaces)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectHandlerStatus) DeepCopyInto(out *ObjectHandlerStatus) {
+func (in *MatrixEntryObject) DeepCopyInto(out *MatrixEntryObject) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	out.Ref = in.Ref
+	if in.JsonPath != nil {
+		in, out := &in.JsonPath, &out.JsonPath
+		*out = new(string)
+		**out = **in
 	}
-	if in.HandlerStatus != nil {
-		in, out := &in.HandlerStatus, &out.HandlerStatus
-		*out = make([]*HandlerStatus, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(HandlerStatus)
-				(*in).DeepCopyInto(*out)
-			}
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandlerStatus.
-func (in *ObjectHandlerStatus) DeepCopy() *ObjectHandlerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryObject.
+func (in *MatrixEntryObject) DeepCopy() *MatrixEntryObject {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectHandlerStatus)
+	out := new(MatrixEntryObject)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectRef) DeepCopyInto(out *ObjectRef) {
+func (in *MatrixEntryObjectList) DeepCopyInto(out *MatrixEntryObjectList) {
 	*out = *in
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JsonPath != nil {
+		in, out := &in.JsonPath, &out.JsonPath
+		*out = new(string)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectRef.
-func (in *ObjectRef) DeepCopy() *ObjectRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryObjectList.
+func (in *MatrixEntryObjectList) DeepCopy() *MatrixEntryObjectList {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectRef)
+	out := new(MatrixEntryObjectList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectTemplate) DeepCopyInto(out *ObjectTemplate) {
+func (in *MatrixEntryObjectTemplate) DeepCopyInto(out *MatrixEntryObjectTemplate) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	out.Ref = in.Ref
+	if in.JsonPath != nil {
+		in, out := &in.JsonPath, &out.JsonPath
+		*out = new(string)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectTemplate.
-func (in *ObjectTemplate) DeepCopy() *ObjectTemplate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryObjectTemplate.
+func (in *MatrixEntryObjectTemplate) DeepCopy() *MatrixEntryObjectTemplate {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectTemplate)
+	out := new(MatrixEntryObjectTemplate)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ObjectTemplate) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixEntryRange) DeepCopyInto(out *MatrixEntryRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntryRange.
+func (in *MatrixEntryRange) DeepCopy() *MatrixEntryRange {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixEntryRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixEntrySecret) DeepCopyInto(out *MatrixEntrySecret) {
+	*out = *in
+	out.Ref = in.Ref
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixEntrySecret.
+func (in *MatrixEntrySecret) DeepCopy() *MatrixEntrySecret {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixEntrySecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixTextTemplate) DeepCopyInto(out *MatrixTextTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixTextTemplate.
+func (in *MatrixTextTemplate) DeepCopy() *MatrixTextTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixTextTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MatrixTextTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixTextTemplateList) DeepCopyInto(out *MatrixTextTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MatrixTextTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixTextTemplateList.
+func (in *MatrixTextTemplateList) DeepCopy() *MatrixTextTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixTextTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MatrixTextTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixTextTemplateSpec) DeepCopyInto(out *MatrixTextTemplateSpec) {
+	*out = *in
+	out.Interval = in.Interval
+	if in.Matrix != nil {
+		in, out := &in.Matrix, &out.Matrix
+		*out = make([]*MatrixEntry, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(MatrixEntry)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.Vars != nil {
+		in, out := &in.Vars, &out.Vars
+		*out = make([]*VarsSource, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(VarsSource)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	out.Target = in.Target
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixTextTemplateSpec.
+func (in *MatrixTextTemplateSpec) DeepCopy() *MatrixTextTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixTextTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixTextTemplateStatus) DeepCopyInto(out *MatrixTextTemplateStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixTextTemplateStatus.
+func (in *MatrixTextTemplateStatus) DeepCopy() *MatrixTextTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixTextTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixTextTemplateTarget) DeepCopyInto(out *MatrixTextTemplateTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixTextTemplateTarget.
+func (in *MatrixTextTemplateTarget) DeepCopy() *MatrixTextTemplateTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixTextTemplateTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedObjectReference) DeepCopyInto(out *NamespacedObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedObjectReference.
+func (in *NamespacedObjectReference) DeepCopy() *NamespacedObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectHandler) DeepCopyInto(out *ObjectHandler) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandler.
+func (in *ObjectHandler) DeepCopy() *ObjectHandler {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectHandler)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ObjectHandler) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectHandlerList) DeepCopyInto(out *ObjectHandlerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ObjectHandler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandlerList.
+func (in *ObjectHandlerList) DeepCopy() *ObjectHandlerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectHandlerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ObjectHandlerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectHandlerSpec) DeepCopyInto(out *ObjectHandlerSpec) {
+	*out = *in
+	out.Interval = in.Interval
+	out.ForObject = in.ForObject
+	if in.Handlers != nil {
+		in, out := &in.Handlers, &out.Handlers
+		*out = make([]Handler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandlerSpec.
+func (in *ObjectHandlerSpec) DeepCopy() *ObjectHandlerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectHandlerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectHandlerStatus) DeepCopyInto(out *ObjectHandlerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HandlerStatus != nil {
+		in, out := &in.HandlerStatus, &out.HandlerStatus
+		*out = make([]*HandlerStatus, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(HandlerStatus)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectHandlerStatus.
+func (in *ObjectHandlerStatus) DeepCopy() *ObjectHandlerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectHandlerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectRef) DeepCopyInto(out *ObjectRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectRef.
+func (in *ObjectRef) DeepCopy() *ObjectRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectTemplate) DeepCopyInto(out *ObjectTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectTemplate.
+func (in *ObjectTemplate) DeepCopy() *ObjectTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ObjectTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectTemplateDebug) DeepCopyInto(out *ObjectTemplateDebug) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectTemplateDebug.
+func (in *ObjectTemplateDebug) DeepCopy() *ObjectTemplateDebug {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectTemplateDebug)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectTemplateInventory) DeepCopyInto(out *ObjectTemplateInventory) {
+	*out = *in
+	out.ConfigMapRef = in.ConfigMapRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectTemplateInventory.
+func (in *ObjectTemplateInventory) DeepCopy() *ObjectTemplateInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectTemplateInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ObjectTemplateList) DeepCopyInto(out *ObjectTemplateList) {
 	*out = *in
@@ -1162,6 +1719,91 @@ func (in *ObjectTemplateList) DeepCopyObject() runtime.Object {
 func (in *ObjectTemplateSpec) DeepCopyInto(out *ObjectTemplateSpec) {
 	*out = *in
 	out.Interval = in.Interval
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.KubeConfig != nil {
+		in, out := &in.KubeConfig, &out.KubeConfig
+		*out = new(KubeConfig)
+		**out = **in
+	}
+	if in.FieldManagerByKind != nil {
+		in, out := &in.FieldManagerByKind, &out.FieldManagerByKind
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExcludeFields != nil {
+		in, out := &in.ExcludeFields, &out.ExcludeFields
+		*out = make([]*ExcludeFields, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ExcludeFields)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	out.ApplyTimeout = in.ApplyTimeout
+	if in.MaxResources != nil {
+		in, out := &in.MaxResources, &out.MaxResources
+		*out = new(int)
+		**out = **in
+	}
+	if in.PruneGracePeriod != nil {
+		in, out := &in.PruneGracePeriod, &out.PruneGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Wait != nil {
+		in, out := &in.Wait, &out.Wait
+		*out = new(ObjectTemplateWait)
+		**out = **in
+	}
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = make([]HealthCheck, len(*in))
+		copy(*out, *in)
+	}
+	if in.Debug != nil {
+		in, out := &in.Debug, &out.Debug
+		*out = new(ObjectTemplateDebug)
+		**out = **in
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(ObjectTemplateInventory)
+		**out = **in
+	}
+	if in.CommonMetadata != nil {
+		in, out := &in.CommonMetadata, &out.CommonMetadata
+		*out = new(CommonMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Vars != nil {
+		in, out := &in.Vars, &out.Vars
+		*out = make([]*VarsSource, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(VarsSource)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.FiltersConfigMapRef != nil {
+		in, out := &in.FiltersConfigMapRef, &out.FiltersConfigMapRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
+	if in.IncludesConfigMapRef != nil {
+		in, out := &in.IncludesConfigMapRef, &out.IncludesConfigMapRef
+		*out = new(LocalObjectReference)
+		**out = **in
+	}
 	if in.Matrix != nil {
 		in, out := &in.Matrix, &out.Matrix
 		*out = make([]*MatrixEntry, len(*in))
@@ -1205,8 +1847,47 @@ func (in *ObjectTemplateStatus) DeepCopyInto(out *ObjectTemplateStatus) {
 	if in.AppliedResources != nil {
 		in, out := &in.AppliedResources, &out.AppliedResources
 		*out = make([]AppliedResourceInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OrphanedResources != nil {
+		in, out := &in.OrphanedResources, &out.OrphanedResources
+		*out = make([]ObjectRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DriftedResources != nil {
+		in, out := &in.DriftedResources, &out.DriftedResources
+		*out = make([]DriftedResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DryRunResults != nil {
+		in, out := &in.DryRunResults, &out.DryRunResults
+		*out = make([]DryRunResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulReconcileTime != nil {
+		in, out := &in.LastSuccessfulReconcileTime, &out.LastSuccessfulReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ObservedSources != nil {
+		in, out := &in.ObservedSources, &out.ObservedSources
+		*out = new(ObservedSources)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectTemplateStatus.
@@ -1219,6 +1900,58 @@ func (in *ObjectTemplateStatus) DeepCopy() *ObjectTemplateStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectTemplateWait) DeepCopyInto(out *ObjectTemplateWait) {
+	*out = *in
+	out.Timeout = in.Timeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectTemplateWait.
+func (in *ObjectTemplateWait) DeepCopy() *ObjectTemplateWait {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectTemplateWait)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObservedSourceObject) DeepCopyInto(out *ObservedSourceObject) {
+	*out = *in
+	out.Ref = in.Ref
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObservedSourceObject.
+func (in *ObservedSourceObject) DeepCopy() *ObservedSourceObject {
+	if in == nil {
+		return nil
+	}
+	out := new(ObservedSourceObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObservedSources) DeepCopyInto(out *ObservedSources) {
+	*out = *in
+	if in.Objects != nil {
+		in, out := &in.Objects, &out.Objects
+		*out = make([]ObservedSourceObject, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObservedSources.
+func (in *ObservedSources) DeepCopy() *ObservedSources {
+	if in == nil {
+		return nil
+	}
+	out := new(ObservedSources)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PullRequestApproveReporter) DeepCopyInto(out *PullRequestApproveReporter) {
 	*out = *in
@@ -1440,6 +2173,11 @@ func (in *SecretRef) DeepCopy() *SecretRef {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Template) DeepCopyInto(out *Template) {
 	*out = *in
+	if in.ExistingRef != nil {
+		in, out := &in.ExistingRef, &out.ExistingRef
+		*out = new(ObjectRef)
+		**out = **in
+	}
 	if in.Object != nil {
 		in, out := &in.Object, &out.Object
 		*out = (*in).DeepCopy()
@@ -1449,6 +2187,11 @@ func (in *Template) DeepCopyInto(out *Template) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(TemplateConfigMapRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Template.
@@ -1461,6 +2204,21 @@ func (in *Template) DeepCopy() *Template {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateConfigMapRef) DeepCopyInto(out *TemplateConfigMapRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateConfigMapRef.
+func (in *TemplateConfigMapRef) DeepCopy() *TemplateConfigMapRef {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateConfigMapRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateRef) DeepCopyInto(out *TemplateRef) {
 	*out = *in
@@ -1653,3 +2411,48 @@ func (in *TextTemplateStatus) DeepCopy() *TextTemplateStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarsSource) DeepCopyInto(out *VarsSource) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(VarsSourceRef)
+		**out = **in
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(VarsSourceRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarsSource.
+func (in *VarsSource) DeepCopy() *VarsSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VarsSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarsSourceRef) DeepCopyInto(out *VarsSourceRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarsSourceRef.
+func (in *VarsSourceRef) DeepCopy() *VarsSourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VarsSourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
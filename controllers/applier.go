@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"context"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Applier sends a single rendered object to the cluster according to the apply mode. On success, obj must contain the
+// object as returned by the API server. opts must be honored by all apply modes, as dry-runs and drift detection pass
+// client.DryRunAll
+type Applier interface {
+	Apply(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, applyMode string, opts ...client.PatchOption) error
+}
+
+// patchApplier is the default Applier, which uses server-side apply, patches or creates, depending on the apply mode
+type patchApplier struct {
+	r *ObjectTemplateReconciler
+}
+
+func (a *patchApplier) Apply(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, applyMode string, opts ...client.PatchOption) error {
+	return a.r.patchRenderedObject(ctx, objClient, rt, obj, applyMode, opts...)
+}
+
+// getApplier returns the configured Applier or the default one for reconcilers that were not set up via
+// SetupWithManager
+func (r *ObjectTemplateReconciler) getApplier() Applier {
+	if r.Applier != nil {
+		return r.Applier
+	}
+	return &patchApplier{r: r}
+}
@@ -2,22 +2,32 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/jmespath/go-jmespath"
 	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
 	"github.com/ohler55/ojg/jp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"math/rand"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 type BaseTemplateReconciler struct {
@@ -27,12 +37,78 @@ type BaseTemplateReconciler struct {
 	Scheme       *runtime.Scheme
 	FieldManager string
 
+	// ControllerName and ControllerVersion are exposed to templates via the `controller` variable
+	ControllerName    string
+	ControllerVersion string
+
+	// AllowedNamespaces restricts the namespaces in which objects can be read and applied. An empty list allows all
+	// namespaces
+	AllowedNamespaces []string
+
+	// WatchDelay delays reconciliations triggered by changes of watched input objects, so that a burst of changes
+	// results in a single reconciliation
+	WatchDelay time.Duration
+
+	// Shard restricts the reconciler to templates with a matching shard label. If empty, only templates without a
+	// shard label are reconciled
+	Shard string
+
+	// IntervalJitter randomizes the requeue interval by up to the given fraction in both directions, so that templates
+	// with the same interval don't reconcile in lockstep
+	IntervalJitter float64
+
+	// newClientForObjects optionally replaces the impersonating client used to read inputs and apply objects, e.g.
+	// with a fake client in tests
+	newClientForObjects func(serviceAccountName string, objNamespace string) (client.Client, error)
+
 	controller   controller.Controller
 	watchedKinds map[schema.GroupVersionKind]bool
 	mutex        sync.Mutex
 }
 
+// isInShard returns true if the object is assigned to the shard of this reconciler
+func (r *BaseTemplateReconciler) isInShard(obj client.Object) bool {
+	return obj.GetLabels()[templatesv1alpha1.ShardLabel] == r.Shard
+}
+
+// jitterInterval randomizes the given interval by up to ±IntervalJitter
+func (r *BaseTemplateReconciler) jitterInterval(interval time.Duration) time.Duration {
+	if r.IntervalJitter <= 0 || interval <= 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + r.IntervalJitter*(rand.Float64()*2-1)))
+}
+
+// buildForPredicates returns the predicates for the reconciled template type. Label changes must also trigger a
+// reconciliation, as these might move the template into or out of our shard
+func (r *BaseTemplateReconciler) buildForPredicates() builder.Predicates {
+	return builder.WithPredicates(
+		predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}, reconcileRequestedPredicate{}),
+		predicate.NewPredicateFuncs(r.isInShard),
+	)
+}
+
+// reconcileRequestedPredicate triggers a reconciliation when the reconcile request annotation changes
+type reconcileRequestedPredicate struct {
+	predicate.Funcs
+}
+
+func (reconcileRequestedPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+	return getReconcileRequest(e.ObjectOld) != getReconcileRequest(e.ObjectNew)
+}
+
+func getReconcileRequest(obj client.Object) string {
+	return obj.GetAnnotations()[templatesv1alpha1.ReconcileRequestAnnotation]
+}
+
 func (r *BaseTemplateReconciler) getClientForObjects(serviceAccountName string, objNamespace string) (client.Client, error) {
+	if r.newClientForObjects != nil {
+		return r.newClientForObjects(serviceAccountName, objNamespace)
+	}
+
 	restConfig, err := config.GetConfig()
 	if err != nil {
 		return nil, err
@@ -55,6 +131,20 @@ func (r *BaseTemplateReconciler) getClientForObjects(serviceAccountName string,
 	return c, nil
 }
 
+// checkNamespaceAllowed returns an error if the given namespace is not in the list of allowed namespaces. Cluster
+// scoped objects (empty namespace) are always allowed
+func (r *BaseTemplateReconciler) checkNamespaceAllowed(namespace string) error {
+	if len(r.AllowedNamespaces) == 0 || namespace == "" {
+		return nil
+	}
+	for _, ns := range r.AllowedNamespaces {
+		if ns == namespace {
+			return nil
+		}
+	}
+	return fmt.Errorf("forbidden: access to namespace %s is not allowed, the controller is restricted to the namespaces %s", namespace, strings.Join(r.AllowedNamespaces, ", "))
+}
+
 func (r *BaseTemplateReconciler) addWatchForKind(ctx context.Context, gvk schema.GroupVersionKind, key string, eventHandler handler.EventHandler) error {
 	logger := log.FromContext(ctx)
 
@@ -85,6 +175,8 @@ func (r *BaseTemplateReconciler) addWatchForKind(ctx context.Context, gvk schema
 	return nil
 }
 
+// buildBaseVars builds the variables that are available in all templates. It is called once per reconciliation, so
+// that `now` is the same for all renders of the same reconciliation
 func (r *BaseTemplateReconciler) buildBaseVars(templateObj runtime.Object, objVarName string) (map[string]any, error) {
 	vars := map[string]any{}
 
@@ -94,6 +186,11 @@ func (r *BaseTemplateReconciler) buildBaseVars(templateObj runtime.Object, objVa
 	}
 
 	vars[objVarName] = u
+	vars["controller"] = map[string]any{
+		"name":    r.ControllerName,
+		"version": r.ControllerVersion,
+	}
+	vars["now"] = time.Now().UTC().Format(time.RFC3339)
 	return vars, nil
 }
 
@@ -107,26 +204,129 @@ func (r *BaseTemplateReconciler) buildObjectInput(ctx context.Context, client cl
 		namespace = ref.Namespace
 	}
 
+	err = r.checkNamespaceAllowed(namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	var o unstructured.Unstructured
 	o.SetGroupVersionKind(gvk)
 
 	err = client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &o)
+	if err != nil {
+		return nil, wrapKindNotInstalledError(gvk, err)
+	}
+
+	results, err := applyJsonPath(o.Object, jsonPath)
 	if err != nil {
 		return nil, err
 	}
 
-	var results []any
+	elems := expandListElements(results, expandLists)
 
-	if jsonPath != nil {
-		jp, err := jp.ParseString(*jsonPath)
+	if expectOne {
+		if len(elems) == 0 {
+			return nil, fmt.Errorf("failed to get object/subElement %s: %w", ref.String(), err)
+		}
+		if len(elems) > 1 {
+			return nil, fmt.Errorf("more than one element returned for object %s and json path %s: %w", ref.String(), *jsonPath, err)
+		}
+	}
+
+	return elems, nil
+}
+
+// buildObjectListInput lists the objects and returns the matrix elements for all of them. exclude is optional and
+// allows to skip individual objects
+func (r *BaseTemplateReconciler) buildObjectListInput(ctx context.Context, c client.Client, namespace string, gvk schema.GroupVersionKind, labelSelector *metav1.LabelSelector, jsonPath *string, expandLists bool, exclude func(o *unstructured.Unstructured) (bool, error)) ([]any, error) {
+	err := r.checkNamespaceAllowed(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []client.ListOption
+	opts = append(opts, client.InNamespace(namespace))
+	if labelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
 		if err != nil {
 			return nil, err
 		}
-		results = jp.Get(o.Object)
-	} else {
-		results = []any{o.Object}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
 	}
 
+	var l unstructured.UnstructuredList
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	err = c.List(ctx, &l, opts...)
+	if err != nil {
+		return nil, wrapKindNotInstalledError(gvk, err)
+	}
+
+	// ensure stable ordering of elements
+	sort.Slice(l.Items, func(i, j int) bool {
+		return l.Items[i].GetName() < l.Items[j].GetName()
+	})
+
+	var elems []any
+	for _, o := range l.Items {
+		if exclude != nil {
+			excluded, err := exclude(&o)
+			if err != nil {
+				return nil, err
+			}
+			if excluded {
+				continue
+			}
+		}
+		results, err := applyJsonPath(o.Object, jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, expandListElements(results, expandLists)...)
+	}
+	return elems, nil
+}
+
+// applyJsonPath returns all results of the given JSON path. If no JSON path is specified, the whole object is returned
+func applyJsonPath(o any, jsonPath *string) ([]any, error) {
+	if jsonPath == nil {
+		return []any{o}, nil
+	}
+	x, err := jp.ParseString(*jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	return x.Get(o), nil
+}
+
+// applyJmesPath returns a single result, or no result at all if the expression did not match anything. This makes
+// it behave the same as applyJsonPath with regard to expandListElements
+func applyJmesPath(o any, expr *string) ([]any, error) {
+	if expr == nil {
+		return []any{o}, nil
+	}
+
+	// go-jmespath only handles float64 numbers properly, so we need to normalize the input
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(b, &normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := jmespath.Search(*expr, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if x == nil {
+		return nil, nil
+	}
+	return []any{x}, nil
+}
+
+func expandListElements(results []any, expandLists bool) []any {
 	var elems []any
 	for _, x := range results {
 		if expandLists {
@@ -139,15 +339,5 @@ func (r *BaseTemplateReconciler) buildObjectInput(ctx context.Context, client cl
 			elems = append(elems, x)
 		}
 	}
-
-	if expectOne {
-		if len(elems) == 0 {
-			return nil, fmt.Errorf("failed to get object/subElement %s: %w", ref.String(), err)
-		}
-		if len(elems) > 1 {
-			return nil, fmt.Errorf("more than one element returned for object %s and json path %s: %w", ref.String(), *jsonPath, err)
-		}
-	}
-
-	return elems, nil
+	return elems
 }
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+// clusterInfoTTL specifies how long discovery results are cached. Server versions and available APIs rarely change,
+// so there is no need to query these on every reconciliation
+const clusterInfoTTL = 10 * time.Minute
+
+// clusterInfoCache caches discovery information per API server
+type clusterInfoCache struct {
+	mutex   sync.Mutex
+	entries map[string]*clusterInfoEntry
+}
+
+type clusterInfoEntry struct {
+	info    map[string]any
+	expires time.Time
+}
+
+// get returns the discovery information of the cluster behind restConfig, which is the local cluster if nil. The
+// result must not be modified
+func (c *clusterInfoCache) get(restConfig *rest.Config) (map[string]any, error) {
+	// the local cluster is cached with an empty key
+	key := ""
+	if restConfig != nil {
+		key = restConfig.Host
+	}
+	c.mutex.Lock()
+	e, ok := c.entries[key]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.info, nil
+	}
+
+	if restConfig == nil {
+		var err error
+		restConfig, err = config.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := loadClusterInfo(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]*clusterInfoEntry{}
+	}
+	c.entries[key] = &clusterInfoEntry{
+		info:    info,
+		expires: time.Now().Add(clusterInfoTTL),
+	}
+	return info, nil
+}
+
+// getClusterInfo returns the discovery information of the target cluster, see clusterInfoCache.get
+func (r *ObjectTemplateReconciler) getClusterInfo(restConfig *rest.Config) (map[string]any, error) {
+	if r.newClusterInfo != nil {
+		return r.newClusterInfo(restConfig)
+	}
+	return r.clusterInfoCache.get(restConfig)
+}
+
+// loadClusterInfo queries the server version and the available API group versions
+func loadClusterInfo(restConfig *rest.Config) (map[string]any, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := dc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query server version: %w", err)
+	}
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API groups: %w", err)
+	}
+
+	var apiVersions []string
+	for _, g := range groups.Groups {
+		for _, gv := range g.Versions {
+			apiVersions = append(apiVersions, gv.GroupVersion)
+		}
+	}
+	sort.Strings(apiVersions)
+
+	apiVersionsAny := make([]any, 0, len(apiVersions))
+	for _, x := range apiVersions {
+		apiVersionsAny = append(apiVersionsAny, x)
+	}
+
+	return map[string]any{
+		"version": map[string]any{
+			"major":      v.Major,
+			"minor":      v.Minor,
+			"gitVersion": v.GitVersion,
+			"platform":   v.Platform,
+		},
+		"apiVersions": apiVersionsAny,
+	}, nil
+}
+
+// mergeClusterInfo sets the `cluster` variable to the discovery information. User provided vars with the same name
+// are merged on top, so that these can still be used to provide additional information about the cluster
+func mergeClusterInfo(baseVars map[string]any, info map[string]any) {
+	cluster := runtime.DeepCopyJSON(info)
+	if x, ok := baseVars["cluster"]; ok {
+		m, ok := x.(map[string]any)
+		if !ok {
+			// not a map, so we can't merge and keep the user provided value
+			return
+		}
+		MergeMap(cluster, m)
+	}
+	baseVars["cluster"] = cluster
+}
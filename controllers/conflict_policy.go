@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// getConflictPolicy returns the effective conflict policy, taking the legacy forceApply field into account
+func getConflictPolicy(rt *templatesv1alpha1.ObjectTemplate) string {
+	if rt.Spec.ConflictPolicy != "" {
+		return rt.Spec.ConflictPolicy
+	}
+	if rt.Spec.ForceApply {
+		return templatesv1alpha1.ConflictPolicyForce
+	}
+	return templatesv1alpha1.ConflictPolicyFail
+}
+
+// removeConflictingFields removes all fields from obj that are owned by other field managers and have a different
+// value in the live object, so that applying obj does not cause conflicts anymore. Fields that are also owned by our
+// own field manager are kept, as these can never conflict. Returns the paths of the removed fields
+func (r *ObjectTemplateReconciler) removeConflictingFields(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured) ([]string, error) {
+	var live unstructured.Unstructured
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	err := objClient.Get(ctx, client.ObjectKeyFromObject(obj), &live)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldManager := r.getObjectFieldManager(rt, obj)
+	ours := &fieldpath.Set{}
+	others := &fieldpath.Set{}
+	for _, mf := range live.GetManagedFields() {
+		if mf.FieldsV1 == nil {
+			continue
+		}
+		s := &fieldpath.Set{}
+		err = s.FromJSON(bytes.NewReader(mf.FieldsV1.Raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse managed fields of %s: %w", mf.Manager, err)
+		}
+		if mf.Manager == fieldManager && mf.Operation == metav1.ManagedFieldsOperationApply {
+			ours = ours.Union(s)
+		} else {
+			others = others.Union(s)
+		}
+	}
+
+	var removed []string
+	others.Leaves().Difference(ours).Iterate(func(p fieldpath.Path) {
+		if isListKeyField(p) {
+			// removing key fields would make the list element unidentifiable
+			return
+		}
+		v, ok := lookupFieldPath(obj.Object, p)
+		if !ok {
+			return
+		}
+		lv, ok := lookupFieldPath(live.Object, p)
+		if ok && value.Equals(value.NewValueInterface(v), value.NewValueInterface(lv)) {
+			// same value, so this field is simply co-owned
+			return
+		}
+		nv, ok := removeFieldPath(obj.Object, p)
+		if ok {
+			obj.Object = nv.(map[string]any)
+			removed = append(removed, p.String())
+		}
+	})
+	return removed, nil
+}
+
+// isListKeyField returns true if the path points to a key field of an associative list element
+func isListKeyField(p fieldpath.Path) bool {
+	if len(p) < 2 || p[len(p)-1].FieldName == nil || p[len(p)-2].Key == nil {
+		return false
+	}
+	for _, f := range *p[len(p)-2].Key {
+		if f.Name == *p[len(p)-1].FieldName {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupFieldPath returns the value at the given path
+func lookupFieldPath(v any, p fieldpath.Path) (any, bool) {
+	for _, pe := range p {
+		if pe.FieldName != nil {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			v, ok = m[*pe.FieldName]
+			if !ok {
+				return nil, false
+			}
+			continue
+		}
+		l, ok := v.([]any)
+		if !ok {
+			return nil, false
+		}
+		i := findListElement(l, pe)
+		if i == -1 {
+			return nil, false
+		}
+		v = l[i]
+	}
+	return v, true
+}
+
+// removeFieldPath removes the value at the given path and returns the modified value
+func removeFieldPath(v any, p fieldpath.Path) (any, bool) {
+	pe := p[0]
+	if pe.FieldName != nil {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v, false
+		}
+		c, ok := m[*pe.FieldName]
+		if !ok {
+			return v, false
+		}
+		if len(p) == 1 {
+			delete(m, *pe.FieldName)
+			return m, true
+		}
+		nc, removed := removeFieldPath(c, p[1:])
+		m[*pe.FieldName] = nc
+		return m, removed
+	}
+
+	l, ok := v.([]any)
+	if !ok {
+		return v, false
+	}
+	i := findListElement(l, pe)
+	if i == -1 {
+		return v, false
+	}
+	if len(p) == 1 {
+		return append(l[:i:i], l[i+1:]...), true
+	}
+	nc, removed := removeFieldPath(l[i], p[1:])
+	l[i] = nc
+	return l, removed
+}
+
+// findListElement returns the index of the list element identified by the path element or -1 if not found
+func findListElement(l []any, pe fieldpath.PathElement) int {
+	for i, e := range l {
+		switch {
+		case pe.Key != nil:
+			m, ok := e.(map[string]any)
+			if !ok {
+				continue
+			}
+			match := true
+			for _, f := range *pe.Key {
+				fv, ok := m[f.Name]
+				if !ok || !value.Equals(value.NewValueInterface(fv), f.Value) {
+					match = false
+					break
+				}
+			}
+			if match {
+				return i
+			}
+		case pe.Value != nil:
+			if value.Equals(value.NewValueInterface(e), *pe.Value) {
+				return i
+			}
+		case pe.Index != nil:
+			if *pe.Index == i {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+func buildManagedFields(manager string, operation metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  operation,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func buildDeployment(image string, replicas int64) *unstructured.Unstructured {
+	o := buildTestObject("Deployment", "ns", "d")
+	o.SetAPIVersion("apps/v1")
+	o.Object["spec"] = map[string]any{
+		"replicas": replicas,
+		"template": map[string]any{
+			"spec": map[string]any{
+				"containers": []any{
+					map[string]any{"name": "c", "image": image},
+				},
+			},
+		},
+	}
+	return o
+}
+
+func TestRemoveConflictingFields(t *testing.T) {
+	tests := []struct {
+		name          string
+		live          *unstructured.Unstructured
+		managedFields []metav1.ManagedFieldsEntry
+		rendered      *unstructured.Unstructured
+		wantRemoved   []string
+		// wantObject defaults to the unmodified rendered object
+		wantObject *unstructured.Unstructured
+	}{
+		{
+			name: "scalar owned by other manager with different value",
+			live: buildDeployment("old", 1),
+			managedFields: []metav1.ManagedFieldsEntry{
+				buildManagedFields("other", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:replicas":{}}}`),
+			},
+			rendered:    buildDeployment("old", 3),
+			wantRemoved: []string{".spec.replicas"},
+			wantObject: func() *unstructured.Unstructured {
+				o := buildDeployment("old", 3)
+				unstructured.RemoveNestedField(o.Object, "spec", "replicas")
+				return o
+			}(),
+		},
+		{
+			name: "co-owned equal value",
+			live: buildDeployment("old", 3),
+			managedFields: []metav1.ManagedFieldsEntry{
+				buildManagedFields("other", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:replicas":{}}}`),
+			},
+			rendered: buildDeployment("old", 3),
+		},
+		{
+			name: "field also owned by our field manager",
+			live: buildDeployment("old", 1),
+			managedFields: []metav1.ManagedFieldsEntry{
+				buildManagedFields("other", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:replicas":{}}}`),
+				buildManagedFields("template-controller", metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:replicas":{}}}`),
+			},
+			rendered: buildDeployment("old", 3),
+		},
+		{
+			name: "associative list keeps key fields",
+			live: buildDeployment("old", 1),
+			managedFields: []metav1.ManagedFieldsEntry{
+				buildManagedFields("other", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"c\"}":{".":{},"f:name":{},"f:image":{}}}}}}}`),
+			},
+			rendered:    buildDeployment("new", 1),
+			wantRemoved: []string{`.spec.template.spec.containers[name="c"].image`},
+			wantObject: func() *unstructured.Unstructured {
+				o := buildDeployment("new", 1)
+				_ = unstructured.SetNestedSlice(o.Object, []any{map[string]any{"name": "c"}}, "spec", "template", "spec", "containers")
+				return o
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.live.SetManagedFields(tt.managedFields)
+			c := newFakeClient(tt.live)
+			r := newFakeReconciler(c)
+
+			rendered := tt.rendered.DeepCopy()
+			removed, err := r.removeConflictingFields(context.Background(), c, &templatesv1alpha1.ObjectTemplate{}, rendered)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(removed)
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			want := tt.wantObject
+			if want == nil {
+				want = tt.rendered
+			}
+			if !reflect.DeepEqual(rendered.Object, want.Object) {
+				t.Errorf("object = %v, want %v", rendered.Object, want.Object)
+			}
+		})
+	}
+}
+
+func TestIsListKeyField(t *testing.T) {
+	key := fieldpath.KeyByFields("name", "c")
+	tests := []struct {
+		name string
+		p    fieldpath.Path
+		want bool
+	}{
+		{name: "key field", p: fieldpath.MakePathOrDie("containers", key, "name"), want: true},
+		{name: "non-key field", p: fieldpath.MakePathOrDie("containers", key, "image"), want: false},
+		{name: "plain field", p: fieldpath.MakePathOrDie("spec", "name"), want: false},
+		{name: "set element", p: fieldpath.MakePathOrDie("finalizers", value.NewValueInterface("a")), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isListKeyField(tt.p); got != tt.want {
+				t.Errorf("isListKeyField(%s) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveFieldPathListElements(t *testing.T) {
+	tests := []struct {
+		name string
+		p    fieldpath.Path
+		want map[string]any
+	}{
+		{
+			name: "set element",
+			p:    fieldpath.MakePathOrDie("finalizers", value.NewValueInterface("b")),
+			want: map[string]any{
+				"finalizers": []any{"a", "c"},
+				"items":      []any{map[string]any{"x": "1"}, map[string]any{"x": "2"}},
+			},
+		},
+		{
+			name: "index element",
+			p:    fieldpath.MakePathOrDie("items", 0),
+			want: map[string]any{
+				"finalizers": []any{"a", "b", "c"},
+				"items":      []any{map[string]any{"x": "2"}},
+			},
+		},
+		{
+			name: "field of index element",
+			p:    fieldpath.MakePathOrDie("items", 1, "x"),
+			want: map[string]any{
+				"finalizers": []any{"a", "b", "c"},
+				"items":      []any{map[string]any{"x": "1"}, map[string]any{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := map[string]any{
+				"finalizers": []any{"a", "b", "c"},
+				"items":      []any{map[string]any{"x": "1"}, map[string]any{"x": "2"}},
+			}
+			nv, ok := removeFieldPath(v, tt.p)
+			if !ok {
+				t.Fatalf("%s was not removed", tt.p)
+			}
+			if !reflect.DeepEqual(nv, tt.want) {
+				t.Errorf("got %v, want %v", nv, tt.want)
+			}
+		})
+	}
+
+	l := []any{"a", "b"}
+	if i := findListElement(l, fieldpath.PathElement{Value: ptrValue(value.NewValueInterface("c"))}); i != -1 {
+		t.Errorf("expected missing set element to not be found, got %d", i)
+	}
+	if _, ok := removeFieldPath(map[string]any{"items": l}, fieldpath.MakePathOrDie("items", 5)); ok {
+		t.Errorf("expected out of range index to not be removed")
+	}
+}
+
+func ptrValue(v value.Value) *value.Value {
+	return &v
+}
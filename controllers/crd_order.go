@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// crdEstablishTimeout is the maximum duration to wait for applied CRDs to become established
+const crdEstablishTimeout = 30 * time.Second
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+func isCRD(x *unstructured.Unstructured) bool {
+	return x.GroupVersionKind().GroupKind() == crdGroupKind
+}
+
+// buildRenderedCRDMappings returns REST mappings for all kinds defined by rendered CRDs. These are used for objects
+// whose CRD does not exist on the cluster yet, as the RESTMapper can't know about them before the CRD is applied
+func buildRenderedCRDMappings(objs []*unstructured.Unstructured) map[schema.GroupKind]*apimeta.RESTMapping {
+	ret := map[schema.GroupKind]*apimeta.RESTMapping{}
+	for _, x := range objs {
+		if !isCRD(x) {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(x.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(x.Object, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(x.Object, "spec", "names", "plural")
+		scope, _, _ := unstructured.NestedString(x.Object, "spec", "scope")
+		if group == "" || kind == "" {
+			continue
+		}
+		rm := &apimeta.RESTMapping{
+			GroupVersionKind: schema.GroupVersionKind{Group: group, Kind: kind},
+			Resource:         schema.GroupVersionResource{Group: group, Resource: plural},
+			Scope:            apimeta.RESTScopeRoot,
+		}
+		if scope == "Namespaced" {
+			rm.Scope = apimeta.RESTScopeNamespace
+		}
+		ret[rm.GroupVersionKind.GroupKind()] = rm
+	}
+	return ret
+}
+
+// waitForCRDsEstablished waits until all given CRDs have the Established condition set to True, so that objects of the
+// defined kinds can be applied afterwards
+func waitForCRDsEstablished(ctx context.Context, c client.Client, crds []*unstructured.Unstructured) error {
+	pending := crds
+	var lastErrs *multierror.Error
+	err := wait.PollUntilContextTimeout(ctx, time.Second, crdEstablishTimeout, true, func(ctx context.Context) (bool, error) {
+		var stillPending []*unstructured.Unstructured
+		lastErrs = nil
+		for _, x := range pending {
+			o := &unstructured.Unstructured{}
+			o.SetGroupVersionKind(x.GroupVersionKind())
+			err := c.Get(ctx, client.ObjectKeyFromObject(x), o)
+			if err != nil {
+				return false, err
+			}
+			if !isCRDEstablished(o) {
+				stillPending = append(stillPending, x)
+				lastErrs = multierror.Append(lastErrs, fmt.Errorf("%s is not established yet", renderedObjectString(x)))
+			}
+		}
+		pending = stillPending
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) && lastErrs != nil {
+			return fmt.Errorf("timed out waiting for CRDs: %w", lastErrs)
+		}
+		return err
+	}
+	return nil
+}
+
+func isCRDEstablished(o *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(o.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if m["type"] == "Established" && m["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"github.com/ohler55/ojg/jp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func matchesExcludeFields(ef *templatesv1alpha1.ExcludeFields, x *unstructured.Unstructured) bool {
+	gvk := x.GroupVersionKind()
+	if ef.Group != nil && *ef.Group != gvk.Group {
+		return false
+	}
+	if ef.Kind != "" && ef.Kind != gvk.Kind {
+		return false
+	}
+	if ef.Name != "" && ef.Name != x.GetName() {
+		return false
+	}
+	return true
+}
+
+// excludeFields removes all fields specified via spec.excludeFields from the rendered object, so that the controller
+// does not take ownership of these fields when applying the object
+func excludeFields(rt *templatesv1alpha1.ObjectTemplate, x *unstructured.Unstructured) error {
+	for i, ef := range rt.Spec.ExcludeFields {
+		if ef == nil || !matchesExcludeFields(ef, x) {
+			continue
+		}
+		for _, p := range ef.JsonPaths {
+			expr, err := jp.ParseString(p)
+			if err != nil {
+				return fmt.Errorf("invalid jsonPath '%s' in excludeFields[%d]: %w", p, i, err)
+			}
+			nv, err := expr.Remove(x.Object)
+			if err != nil {
+				return fmt.Errorf("failed to exclude '%s' from %s: %w", p, renderedObjectString(x), err)
+			}
+			m, ok := nv.(map[string]any)
+			if !ok {
+				return fmt.Errorf("failed to exclude '%s' from %s: the whole object can not be excluded", p, renderedObjectString(x))
+			}
+			x.Object = m
+		}
+		if x.GetAPIVersion() == "" || x.GetKind() == "" || x.GetName() == "" {
+			return fmt.Errorf("excludeFields[%d] must not exclude apiVersion, kind or metadata.name of %s", i, renderedObjectString(x))
+		}
+	}
+	return nil
+}
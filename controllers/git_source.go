@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/gobwas/glob"
+	"github.com/kluctl/kluctl/v2/pkg/git"
+	"github.com/kluctl/kluctl/v2/pkg/git/auth"
+	"github.com/kluctl/kluctl/v2/pkg/git/messages"
+	ssh_pool "github.com/kluctl/kluctl/v2/pkg/git/ssh-pool"
+	types2 "github.com/kluctl/kluctl/v2/pkg/types"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	yaml3 "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// openGitMirror opens the local mirror of the given repository and locks it. The mirror is only fetched if it was not
+// updated within maxAge, so that multiple reconciliations in short succession (e.g. caused by watch events) don't
+// hammer the Git server. The caller must unlock the returned mirror
+func openGitMirror(ctx context.Context, urlStr string, baseDir string, sshPool *ssh_pool.SshPool, ga *auth.GitAuthProviders, maxAge time.Duration) (*git.MirroredGitRepo, error) {
+	url, err := types2.ParseGitUrl(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	mr, err := git.NewMirroredGitRepo(ctx, *url, filepath.Join(baseDir, "git-mirrors"), sshPool, ga)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mr.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	if maxAge > 0 && time.Since(mr.LastUpdateTime()) < maxAge {
+		mr.SetUpdated(true)
+		return mr, nil
+	}
+
+	err = mr.Update()
+	if err != nil {
+		_ = mr.Unlock()
+		return nil, err
+	}
+	return mr, nil
+}
+
+// filterGitRefs returns all remote refs of the mirror that match the given reference, mapped to their commit hashes.
+// If ref is nil, the default branch is returned
+func filterGitRefs(ref *templatesv1alpha1.GitRef, mr *git.MirroredGitRepo) (map[string]string, error) {
+	refs, err := mr.RemoteRefHashesMap()
+	if err != nil {
+		return nil, err
+	}
+
+	matchingRefs := map[string]string{}
+
+	if ref == nil {
+		defaultRef, err := mr.DefaultRef()
+		if err != nil {
+			return nil, err
+		}
+		hash, ok := refs[defaultRef]
+		if !ok {
+			return nil, fmt.Errorf("default ref %s not found", defaultRef)
+		}
+		matchingRefs[defaultRef] = hash
+		return matchingRefs, nil
+	}
+
+	if ref.Commit != "" {
+		found := false
+		for name, hash := range refs {
+			if hash == ref.Commit {
+				matchingRefs[name] = hash
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("commit %s not found", ref.Commit)
+		}
+		return matchingRefs, nil
+	}
+
+	var regex *regexp.Regexp
+	if ref.Tag != "" {
+		regex, err = regexp.Compile(fmt.Sprintf("^refs/tags/%s$", ref.Tag))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag regex specified: %w", err)
+		}
+	} else if ref.Branch != "" {
+		regex, err = regexp.Compile(fmt.Sprintf("^refs/heads/%s$", ref.Branch))
+		if err != nil {
+			return nil, fmt.Errorf("invalid branch regex specified: %w", err)
+		}
+	} else {
+		return nil, fmt.Errorf("ref is empty")
+	}
+
+	for name, hash := range refs {
+		if regex.MatchString(name) {
+			matchingRefs[name] = hash
+		}
+	}
+
+	return matchingRefs, nil
+}
+
+// buildGitAuth builds the auth providers used to access Git repositories. If secretRef is not nil, the credentials
+// are loaded from the referenced Secret in the given namespace
+func buildGitAuth(ctx context.Context, c client.Client, namespace string, secretRef *templatesv1alpha1.LocalObjectReference) (*auth.GitAuthProviders, error) {
+	logger := log.FromContext(ctx)
+
+	ga := auth.NewDefaultAuthProviders("GIT", &messages.MessageCallbacks{
+		WarningFn: func(s string) {
+			logger.Info(s)
+		},
+		TraceFn: func(s string) {
+			logger.V(1).Info(s)
+		},
+	})
+
+	if secretRef == nil {
+		return ga, nil
+	}
+
+	var gitSecret corev1.Secret
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: secretRef.Name}, &gitSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	e := auth.AuthEntry{
+		Host:     "*",
+		Username: "*",
+	}
+
+	if x, ok := gitSecret.Data["username"]; ok {
+		e.Username = string(x)
+	}
+	if x, ok := gitSecret.Data["password"]; ok {
+		e.Password = string(x)
+	}
+	if x, ok := gitSecret.Data["caFile"]; ok {
+		e.CABundle = x
+	}
+	if x, ok := gitSecret.Data["known_hosts"]; ok {
+		e.KnownHosts = x
+	}
+	if x, ok := gitSecret.Data["identity"]; ok {
+		e.SshKey = x
+	}
+
+	var la auth.ListAuthProvider
+	la.AddEntry(e)
+	ga.RegisterAuthProvider(&la, false)
+	return ga, nil
+}
+
+// parseYamlDocuments parses all YAML documents found in content. The documents are normalized via JSON, so that they
+// can be used as matrix inputs
+func parseYamlDocuments(content string) ([]any, error) {
+	var ret []any
+	d := yaml3.NewDecoder(strings.NewReader(content))
+	for {
+		var a any
+		err := d.Decode(&a)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			// empty document
+			continue
+		}
+		b, err := json.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		var v any
+		err = json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+func (r *ObjectTemplateReconciler) buildGitInput(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, me *templatesv1alpha1.MatrixEntryGit) ([]any, error) {
+	if me.SecretRef != nil {
+		err := r.checkNamespaceAllowed(rt.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+	}
+	ga, err := buildGitAuth(ctx, objClient, rt.GetNamespace(), me.SecretRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load git credentials for %s: %w", me.URL, err)
+	}
+
+	g, err := glob.Compile(me.Path, '/')
+	if err != nil {
+		return nil, fmt.Errorf("invalid path glob %s: %w", me.Path, err)
+	}
+
+	mr, err := openGitMirror(ctx, me.URL, r.TmpBaseDir, &r.sshPool, ga, rt.Spec.Interval.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch git repository %s: %w", me.URL, err)
+	}
+	defer mr.Unlock()
+
+	matchingRefs, err := filterGitRefs(me.Reference, mr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ref for git repository %s: %w", me.URL, err)
+	}
+	if len(matchingRefs) != 1 {
+		return nil, fmt.Errorf("expected exactly one matching ref in git repository %s, found %d", me.URL, len(matchingRefs))
+	}
+	var refName, hash string
+	for n, h := range matchingRefs {
+		refName, hash = n, h
+	}
+
+	t, err := mr.GetGitTreeByCommit(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkout %s of git repository %s: %w", refName, me.URL, err)
+	}
+
+	var results []any
+	err = t.Files().ForEach(func(file *object.File) error {
+		if !g.Match(file.Name) {
+			return nil
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		docs, err := parseYamlDocuments(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s as yaml: %w", file.Name, err)
+		}
+		results = append(results, docs...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files from git repository %s: %w", me.URL, err)
+	}
+
+	return expandListElements(results, me.ExpandLists), nil
+}
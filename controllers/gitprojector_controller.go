@@ -23,25 +23,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/kluctl/kluctl/v2/pkg/git/messages"
-	types2 "github.com/kluctl/kluctl/v2/pkg/types"
 	"io"
-	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/gobwas/glob"
-	"github.com/kluctl/kluctl/v2/pkg/git"
-	"github.com/kluctl/kluctl/v2/pkg/git/auth"
 	ssh_pool "github.com/kluctl/kluctl/v2/pkg/git/ssh-pool"
 	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
 	yaml3 "gopkg.in/yaml.v3"
-	corev1 "k8s.io/api/core/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -136,33 +128,18 @@ func (r *GitProjectorReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 func (r *GitProjectorReconciler) doReconcile(ctx context.Context, obj *templatesv1alpha1.GitProjector) error {
-	url, err := types2.ParseGitUrl(obj.Spec.URL)
+	auth, err := buildGitAuth(ctx, r.Client, obj.Namespace, obj.Spec.SecretRef)
 	if err != nil {
 		return err
 	}
 
-	auth, err := r.buildGitAuth(ctx, obj)
-	if err != nil {
-		return err
-	}
-
-	mr, err := git.NewMirroredGitRepo(ctx, *url, filepath.Join(r.TmpBaseDir, "git-mirrors"), &r.sshPool, auth)
-	if err != nil {
-		return err
-	}
-
-	err = mr.Lock()
+	mr, err := openGitMirror(ctx, obj.Spec.URL, r.TmpBaseDir, &r.sshPool, auth, 0)
 	if err != nil {
 		return err
 	}
 	defer mr.Unlock()
 
-	err = mr.Update()
-	if err != nil {
-		return err
-	}
-
-	matchingRefs, err := r.filterRefs(obj, mr)
+	matchingRefs, err := filterGitRefs(obj.Spec.Reference, mr)
 	if err != nil {
 		return err
 	}
@@ -278,115 +255,6 @@ func (r *GitProjectorReconciler) doReconcile(ctx context.Context, obj *templates
 	return nil
 }
 
-func (r *GitProjectorReconciler) filterRefs(obj *templatesv1alpha1.GitProjector, mr *git.MirroredGitRepo) (map[string]string, error) {
-	refs, err := mr.RemoteRefHashesMap()
-	if err != nil {
-		return nil, err
-	}
-
-	matchingRefs := map[string]string{}
-
-	if obj.Spec.Reference == nil {
-		defaultRef, err := mr.DefaultRef()
-		if err != nil {
-			return nil, err
-		}
-		hash, ok := refs[defaultRef]
-		if !ok {
-			return nil, fmt.Errorf("default ref %s not found", defaultRef)
-		}
-		matchingRefs[defaultRef] = hash
-		return matchingRefs, nil
-	}
-
-	if obj.Spec.Reference.Commit != "" {
-		found := false
-		for name, hash := range refs {
-			if hash == obj.Spec.Reference.Commit {
-				matchingRefs[name] = hash
-				found = true
-				break
-			}
-		}
-		if !found {
-			return nil, fmt.Errorf("commit %s not found", obj.Spec.Reference.Commit)
-		}
-		return matchingRefs, nil
-	}
-
-	var regex *regexp.Regexp
-	if obj.Spec.Reference.Tag != "" {
-		regex, err = regexp.Compile(fmt.Sprintf("^refs/tags/%s$", obj.Spec.Reference.Tag))
-		if err != nil {
-			return nil, fmt.Errorf("invalid tag regex specified: %w", err)
-		}
-	} else if obj.Spec.Reference.Branch != "" {
-		regex, err = regexp.Compile(fmt.Sprintf("^refs/heads/%s$", obj.Spec.Reference.Branch))
-		if err != nil {
-			return nil, fmt.Errorf("invalid branch regex specified: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("ref is empty")
-	}
-
-	for name, hash := range refs {
-		if regex.MatchString(name) {
-			matchingRefs[name] = hash
-		}
-	}
-
-	return matchingRefs, nil
-}
-
-func (r *GitProjectorReconciler) buildGitAuth(ctx context.Context, obj *templatesv1alpha1.GitProjector) (*auth.GitAuthProviders, error) {
-	logger := log.FromContext(ctx)
-
-	ga := auth.NewDefaultAuthProviders("GIT", &messages.MessageCallbacks{
-		WarningFn: func(s string) {
-			logger.Info(s)
-		},
-		TraceFn: func(s string) {
-			logger.V(1).Info(s)
-		},
-	})
-
-	if obj.Spec.SecretRef == nil {
-		return ga, nil
-	}
-
-	var gitSecret corev1.Secret
-	err := r.Client.Get(ctx, types.NamespacedName{Namespace: obj.Namespace, Name: obj.Spec.SecretRef.Name}, &gitSecret)
-	if err != nil {
-		return nil, err
-	}
-
-	e := auth.AuthEntry{
-		Host:     "*",
-		Username: "*",
-	}
-
-	if x, ok := gitSecret.Data["username"]; ok {
-		e.Username = string(x)
-	}
-	if x, ok := gitSecret.Data["password"]; ok {
-		e.Password = string(x)
-	}
-	if x, ok := gitSecret.Data["caFile"]; ok {
-		e.CABundle = x
-	}
-	if x, ok := gitSecret.Data["known_hosts"]; ok {
-		e.KnownHosts = x
-	}
-	if x, ok := gitSecret.Data["identity"]; ok {
-		e.SshKey = x
-	}
-
-	var la auth.ListAuthProvider
-	la.AddEntry(e)
-	ga.RegisterAuthProvider(&la, false)
-	return ga, nil
-}
-
 func (r *GitProjectorReconciler) finalize(ctx context.Context, obj *templatesv1alpha1.GitProjector) (ctrl.Result, error) {
 	r.doFinalize(ctx, obj)
 
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/kluctl/go-jinja2"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// healthFunc computes the health of a live object
+type healthFunc func(o *unstructured.Unstructured) (*status.Result, error)
+
+// buildHealthFunc returns a healthFunc that uses the matching entry of spec.healthChecks and falls back to kstatus for
+// all other kinds
+func buildHealthFunc(j2 *jinja2.Jinja2, rt *templatesv1alpha1.ObjectTemplate, j2Opts []jinja2.Jinja2Opt) healthFunc {
+	return func(o *unstructured.Unstructured) (*status.Result, error) {
+		gk := o.GroupVersionKind().GroupKind()
+		for i := range rt.Spec.HealthChecks {
+			hc := &rt.Spec.HealthChecks[i]
+			if hc.Group == gk.Group && hc.Kind == gk.Kind {
+				return evalHealthCheck(j2, hc, o, j2Opts), nil
+			}
+		}
+		return status.Compute(o)
+	}
+}
+
+// evalHealthCheck evaluates the expressions of the health check. Evaluation errors don't abort waiting, as these are
+// often caused by status fields that are not set yet. These are reported when the wait times out instead
+func evalHealthCheck(j2 *jinja2.Jinja2, hc *templatesv1alpha1.HealthCheck, o *unstructured.Unstructured, j2Opts []jinja2.Jinja2Opt) *status.Result {
+	vars := map[string]any{
+		"object": o.Object,
+	}
+	if hc.Failed != "" {
+		failed, err := EvalJinja2Condition(j2, hc.Failed, vars, j2Opts...)
+		if err != nil {
+			return &status.Result{Status: status.InProgressStatus, Message: fmt.Sprintf("failed to evaluate failed expression: %s", err)}
+		}
+		if failed {
+			return &status.Result{Status: status.FailedStatus, Message: fmt.Sprintf("health check failed: %s", hc.Failed)}
+		}
+	}
+	ready, err := EvalJinja2Condition(j2, hc.Ready, vars, j2Opts...)
+	if err != nil {
+		return &status.Result{Status: status.InProgressStatus, Message: fmt.Sprintf("failed to evaluate ready expression: %s", err)}
+	}
+	if !ready {
+		return &status.Result{Status: status.InProgressStatus, Message: fmt.Sprintf("health check not satisfied yet: %s", hc.Ready)}
+	}
+	return &status.Result{Status: status.CurrentStatus, Message: "health check satisfied"}
+}
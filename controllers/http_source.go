@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHttpSourceTimeout = 10 * time.Second
+	// maxHttpSourceTimeout caps the timeout of HTTP sources, regardless of the timeout specified in the matrix entry
+	maxHttpSourceTimeout = time.Minute
+	// maxHttpSourceSize is the maximum size in bytes of a response body, as responses are held in memory and cached
+	maxHttpSourceSize = 10 * 1024 * 1024
+	// maxHttpSourceRedirects is the maximum number of redirects that are followed
+	maxHttpSourceRedirects = 5
+)
+
+// httpSourceClient is used for all HTTP sources instead of http.DefaultClient, so that the timeout and redirects are
+// limited independent of other users of the default client
+var httpSourceClient = &http.Client{
+	Timeout: maxHttpSourceTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxHttpSourceRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxHttpSourceRedirects)
+		}
+		return nil
+	},
+}
+
+// httpSourceCache caches the responses of HTTP matrix sources, so that endpoints are not requested more often than
+// the interval of the requesting ObjectTemplate
+type httpSourceCache struct {
+	mutex   sync.Mutex
+	entries map[string]httpSourceCacheEntry
+}
+
+type httpSourceCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+func (c *httpSourceCache) get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (c *httpSourceCache) set(key string, body []byte, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]httpSourceCacheEntry{}
+	}
+
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = httpSourceCacheEntry{
+		body:    body,
+		expires: now.Add(ttl),
+	}
+}
+
+func buildHttpSourceCacheKey(url string, headers map[string]string) string {
+	var keys []string
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(url)
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("\n%s: %s", k, headers[k]))
+	}
+	return Sha256String(b.String())
+}
+
+func fetchHttpSource(ctx context.Context, url string, headers map[string]string, timeout time.Duration) ([]byte, error) {
+	if timeout == 0 {
+		timeout = defaultHttpSourceTimeout
+	} else if timeout > maxHttpSourceTimeout {
+		timeout = maxHttpSourceTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpSourceClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP request to %s failed with status %s", url, resp.Status)
+	}
+
+	// one more byte than allowed is read, so that exceeding the limit can be detected
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHttpSourceSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+	if len(body) > maxHttpSourceSize {
+		return nil, fmt.Errorf("response from %s exceeds the maximum size of %d bytes", url, maxHttpSourceSize)
+	}
+	return body, nil
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchHttpSourceLimits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"a": 1}`))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", maxHttpSourceSize+1)))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	body, err := fetchHttpSource(context.Background(), s.URL+"/small", nil, 0)
+	if err != nil || string(body) != `{"a": 1}` {
+		t.Errorf("unexpected result: %q, %v", body, err)
+	}
+
+	_, err = fetchHttpSource(context.Background(), s.URL+"/large", nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size") {
+		t.Errorf("expected size error, got %v", err)
+	}
+
+	_, err = fetchHttpSource(context.Background(), s.URL+"/loop", nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("expected redirect error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const inventoryKey = "inventory.json"
+
+type inventory struct {
+	AppliedResources  []inventoryEntry              `json:"appliedResources"`
+	OrphanedResources []templatesv1alpha1.ObjectRef `json:"orphanedResources"`
+}
+
+type inventoryEntry struct {
+	Ref     templatesv1alpha1.ObjectRef `json:"ref"`
+	Success bool                        `json:"success"`
+}
+
+// buildInventory serializes the applied and orphaned objects from the status. Both lists are already sorted, so the
+// output only changes when the managed objects change
+func buildInventory(rt *templatesv1alpha1.ObjectTemplate) (string, error) {
+	inv := inventory{
+		AppliedResources:  make([]inventoryEntry, 0, len(rt.Status.AppliedResources)),
+		OrphanedResources: make([]templatesv1alpha1.ObjectRef, 0, len(rt.Status.OrphanedResources)),
+	}
+	for _, ari := range rt.Status.AppliedResources {
+		inv.AppliedResources = append(inv.AppliedResources, inventoryEntry{
+			Ref:     ari.Ref,
+			Success: ari.Success,
+		})
+	}
+	inv.OrphanedResources = append(inv.OrphanedResources, rt.Status.OrphanedResources...)
+
+	b, err := json.MarshalIndent(&inv, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// storeInventory writes the inventory into the ConfigMap referred by spec.inventory.configMapRef. The ConfigMap is
+// owned by the ObjectTemplate, so that it gets garbage collected together with the ObjectTemplate
+func (r *ObjectTemplateReconciler) storeInventory(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) error {
+	output, err := buildInventory(rt)
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{}
+	cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	cm.SetNamespace(rt.GetNamespace())
+	cm.SetName(rt.Spec.Inventory.ConfigMapRef.Name)
+	cm.Data = map[string]string{
+		inventoryKey: output,
+	}
+	err = controllerutil.SetOwnerReference(rt, cm, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	err = objClient.Patch(ctx, cm, client.Apply, client.FieldOwner(r.getFieldManager(rt)), client.ForceOwnership)
+	if err != nil {
+		return fmt.Errorf("failed to store inventory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"github.com/kluctl/go-jinja2"
+)
+
+// jinja2Pool keeps a bounded number of idle Jinja2 instances around, so that the Python renderer processes don't need
+// to be spawned for every reconciliation. A Jinja2 instance taken from the pool is used exclusively by one
+// reconciliation. Renders done in parallel on the same instance are serialized by go-jinja2 itself.
+// Instance specific options (e.g. custom filters) must not be passed when creating the instances and must instead be
+// passed on each render call.
+type jinja2Pool struct {
+	idle chan *jinja2.Jinja2
+}
+
+func newJinja2Pool(size int) *jinja2Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &jinja2Pool{
+		idle: make(chan *jinja2.Jinja2, size),
+	}
+}
+
+// Get returns an idle instance or creates a new one if no idle instance is available
+func (p *jinja2Pool) Get() (*jinja2.Jinja2, error) {
+	select {
+	case j2 := <-p.idle:
+		return j2, nil
+	default:
+		return NewJinja2()
+	}
+}
+
+// Put returns the instance to the pool. If reuse is false or the pool is full, the instance is closed instead. Callers
+// should pass reuse=false when rendering failed, as the underlying renderer process might be in a broken state.
+// Instances with renders that were abandoned after exceeding the render timeout are never reused. Instances that are
+// not reused are closed asynchronously, as closing waits for abandoned renders.
+func (p *jinja2Pool) Put(j2 *jinja2.Jinja2, reuse bool) {
+	if !reuse || isJinja2Abandoned(j2) {
+		go func() {
+			j2.Close()
+			abandonedJinja2.Delete(j2)
+		}()
+		return
+	}
+	select {
+	case p.idle <- j2:
+	default:
+		j2.Close()
+	}
+}
+
+// Close closes all idle instances
+func (p *jinja2Pool) Close() {
+	for {
+		select {
+		case j2 := <-p.idle:
+			j2.Close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+)
+
+// errKindNotInstalled is returned when an input refers a kind that is unknown to the cluster, which usually means
+// that the CRD providing the kind is not installed (yet)
+var errKindNotInstalled = errors.New("kind not installed")
+
+func isKindNotInstalledError(err error) bool {
+	return errors.Is(err, errKindNotInstalled)
+}
+
+// wrapKindNotInstalledError replaces the cryptic errors of the RESTMapper for unknown kinds with an actionable error.
+// The lazy RESTMapper of controller-runtime reports unknown API groups with a plain error, which is why we also have
+// to match the message
+func wrapKindNotInstalledError(gvk schema.GroupVersionKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	if !apimeta.IsNoMatchError(err) && !strings.Contains(err.Error(), "failed to find API group") {
+		return err
+	}
+	return fmt.Errorf("%w: %s is not known to the cluster, the CRD providing it is probably not installed: %s", errKindNotInstalled, gvk.String(), err.Error())
+}
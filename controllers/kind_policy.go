@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// errKindNotAllowed is returned when rendered objects are rejected by the KindPolicy
+var errKindNotAllowed = errors.New("kind not allowed")
+
+// KindPolicy restricts the kinds of objects that ObjectTemplates are allowed to apply. Patterns have the form
+// `Kind.group` (or just `Kind` for the core group) and may contain shell-like wildcards, e.g.
+// `*.rbac.authorization.k8s.io`. Denied patterns take precedence over allowed patterns. An empty list of allowed
+// patterns allows all kinds that are not denied.
+type KindPolicy struct {
+	Allowed []string
+	Denied  []string
+}
+
+// ParseKindPolicy parses the comma separated lists of allowed and denied patterns
+func ParseKindPolicy(allowed string, denied string) (*KindPolicy, error) {
+	var p KindPolicy
+	var err error
+	p.Allowed, err = parseKindPatterns(allowed)
+	if err != nil {
+		return nil, err
+	}
+	p.Denied, err = parseKindPatterns(denied)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func parseKindPatterns(s string) ([]string, error) {
+	var ret []string
+	for _, x := range strings.Split(s, ",") {
+		x = strings.TrimSpace(x)
+		if x == "" {
+			continue
+		}
+		if _, err := path.Match(x, ""); err != nil {
+			return nil, fmt.Errorf("invalid kind pattern %s: %w", x, err)
+		}
+		ret = append(ret, x)
+	}
+	return ret, nil
+}
+
+func matchKindPatterns(patterns []string, gk schema.GroupKind) bool {
+	s := gk.String()
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowed returns true if objects of the given kind may be applied
+func (p *KindPolicy) IsAllowed(gk schema.GroupKind) bool {
+	if p == nil {
+		return true
+	}
+	if matchKindPatterns(p.Denied, gk) {
+		return false
+	}
+	if len(p.Allowed) == 0 {
+		return true
+	}
+	return matchKindPatterns(p.Allowed, gk)
+}
+
+func isKindNotAllowedError(err error) bool {
+	return errors.Is(err, errKindNotAllowed)
+}
+
+// checkObjects returns an error that lists all objects that are not allowed by the policy
+func (p *KindPolicy) checkObjects(objs []*unstructured.Unstructured) error {
+	var violations []string
+	for _, x := range objs {
+		if !p.IsAllowed(x.GroupVersionKind().GroupKind()) {
+			violations = append(violations, renderedObjectString(x))
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: the controller does not allow to apply %s", errKindNotAllowed, strings.Join(violations, ", "))
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// coerceMatrixElem converts a parsed list element to the given element type. As list elements must currently be
+// objects, the top-level values of object elements are converted instead when a scalar type is requested
+func coerceMatrixElem(e any, elementType string) (any, error) {
+	m, ok := e.(map[string]any)
+	if !ok || elementType == templatesv1alpha1.ElementTypeObject {
+		return coerceValue(e, elementType)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// sorted, so that the reported error does not depend on map iteration order
+	sort.Strings(keys)
+	ret := make(map[string]any, len(m))
+	for _, k := range keys {
+		v, err := coerceValue(m[k], elementType)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", k, err)
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// coerceValue converts a single value to the given type. Only lossless conversions are performed, e.g. "1" and 1.0
+// become 1 for `int`, while "1.5" and 1.5 result in an error
+func coerceValue(e any, elementType string) (any, error) {
+	switch elementType {
+	case templatesv1alpha1.ElementTypeString:
+		switch v := e.(type) {
+		case string:
+			return v, nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case templatesv1alpha1.ElementTypeInt:
+		switch v := e.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64 {
+				return int64(v), nil
+			}
+		case string:
+			i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an integer", v)
+			}
+			return i, nil
+		}
+	case templatesv1alpha1.ElementTypeBool:
+		switch v := e.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a boolean", v)
+			}
+			return b, nil
+		}
+	case templatesv1alpha1.ElementTypeObject:
+		switch v := e.(type) {
+		case map[string]any:
+			return v, nil
+		case string:
+			// allows to render whole objects as YAML or JSON strings
+			var m map[string]any
+			err := yaml.Unmarshal([]byte(v), &m)
+			if err != nil || m == nil {
+				return nil, fmt.Errorf("string is not a YAML or JSON object")
+			}
+			return m, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown element type %s", elementType)
+	}
+	return nil, fmt.Errorf("can't convert value of type %T", e)
+}
@@ -0,0 +1,418 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/kluctl/go-jinja2"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+)
+
+// MatrixTextTemplateReconciler reconciles a MatrixTextTemplate object
+type MatrixTextTemplateReconciler struct {
+	BaseTemplateReconciler
+
+	// TmpBaseDir is used to store mirrors of Git repositories used as matrix inputs
+	TmpBaseDir string
+
+	// EventRecorder is used to record events, e.g. for missing optional matrix inputs
+	EventRecorder record.EventRecorder
+
+	// RenderLimits optionally restricts the resources used when rendering templates
+	RenderLimits *RenderLimits
+
+	// matrixBuilder is used to build the matrix the same way as ObjectTemplates do
+	matrixBuilder *ObjectTemplateReconciler
+}
+
+//+kubebuilder:rbac:groups=templates.kluctl.io,resources=matrixtexttemplates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=templates.kluctl.io,resources=matrixtexttemplates/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=templates.kluctl.io,resources=matrixtexttemplates/finalizers,verbs=update
+
+// Reconcile a resource
+func (r *MatrixTextTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	logger := log.FromContext(ctx)
+
+	logger.V(1).Info("Starting reconcile")
+	defer logger.V(1).Info("Finished reconcile", "err", err)
+
+	var mt templatesv1alpha1.MatrixTextTemplate
+	err = r.Get(ctx, req.NamespacedName, &mt)
+	if err != nil {
+		logger.Error(err, "Get failed")
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	// the template might have been moved to another shard in the meantime
+	if !r.isInShard(&mt) {
+		return
+	}
+
+	// Return early if the object is suspended.
+	if mt.Spec.Suspend {
+		logger.Info("Reconciliation is suspended for this object")
+		return ctrl.Result{}, nil
+	}
+
+	for _, me := range mt.Spec.Matrix {
+		ref := r.matrixBuilder.buildMatrixEntryRef(me)
+		if ref != nil {
+			gvk, err2 := ref.GroupVersionKind()
+			if err2 != nil {
+				err = err2
+				return
+			}
+			err = r.addWatchForKind(ctx, gvk, forMatrixObjectKey, r.buildWatchEventHandler(forMatrixObjectKey, BuildObjectIndexValue))
+			if err != nil {
+				return
+			}
+			if me.Object != nil && me.Object.IsTemplated() {
+				err = r.addWatchForKind(ctx, gvk, forMatrixObjectListKey, r.buildWatchEventHandler(forMatrixObjectListKey, BuildObjectKindNamespaceIndexValue))
+				if err != nil {
+					return
+				}
+			}
+		}
+		if me.ObjectList != nil {
+			gvk, err2 := me.ObjectList.GroupVersionKind()
+			if err2 != nil {
+				err = err2
+				return
+			}
+			err = r.addWatchForKind(ctx, gvk, forMatrixObjectListKey, r.buildWatchEventHandler(forMatrixObjectListKey, BuildObjectKindNamespaceIndexValue))
+			if err != nil {
+				return
+			}
+		}
+		if me.Namespaces != nil {
+			err = r.addWatchForKind(ctx, namespaceGVK, forMatrixObjectListKey, r.buildWatchEventHandler(forMatrixObjectListKey, BuildObjectKindNamespaceIndexValue))
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	patch := client.MergeFrom(mt.DeepCopy())
+	err = r.doReconcile(ctx, &mt, r.buildMatrixObjectTemplate(&mt))
+	c := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: mt.GetGeneration(),
+		Reason:             "Success",
+		Message:            "Success",
+	}
+	if err != nil {
+		mt.Status.FailureCount++
+		c.Status = metav1.ConditionFalse
+		c.Reason = readyReasonForError(err)
+		c.Message = err.Error()
+	} else {
+		mt.Status.FailureCount = 0
+	}
+	apimeta.SetStatusCondition(&mt.Status.Conditions, c)
+	err = r.Status().Patch(ctx, &mt, patch, SubResourceFieldOwner(r.FieldManager))
+	if err != nil {
+		return
+	}
+
+	result.RequeueAfter = r.jitterInterval(calcErrorBackoff(mt.Spec.Interval.Duration, mt.Status.FailureCount))
+	return
+}
+
+func (r *MatrixTextTemplateReconciler) doReconcile(ctx context.Context, mt *templatesv1alpha1.MatrixTextTemplate, rt *templatesv1alpha1.ObjectTemplate) (retErr error) {
+	// values loaded from secrets must never end up in the status of the MatrixTextTemplate
+	scrubber := &secretScrubber{}
+	defer func() {
+		retErr = scrubber.ScrubError(retErr)
+		mt.Status.Warnings = rt.Status.Warnings
+		for i := range mt.Status.Warnings {
+			mt.Status.Warnings[i] = scrubber.Scrub(mt.Status.Warnings[i])
+		}
+	}()
+
+	if mt.Spec.Target.Name == "" || mt.Spec.Target.Key == "" {
+		return fmt.Errorf("target.name and target.key must be set")
+	}
+
+	objClient, err := r.getClientForObjects(mt.Spec.ServiceAccountName, mt.GetNamespace())
+	if err != nil {
+		return err
+	}
+
+	statusBackup := mt.Status
+	mt.Status = templatesv1alpha1.MatrixTextTemplateStatus{}
+	baseVars, err := r.buildBaseVars(mt, "matrixTextTemplate")
+	mt.Status = statusBackup
+	if err != nil {
+		return err
+	}
+	if len(rt.Spec.Vars) != 0 {
+		vars, err := r.matrixBuilder.buildVars(ctx, objClient, rt, scrubber)
+		if err != nil {
+			return err
+		}
+		baseVars = mergeBaseVars(vars, baseVars)
+	}
+
+	var j2Opts []jinja2.Jinja2Opt
+	if rt.Spec.StrictUndefined {
+		j2Opts = append(j2Opts, jinja2.WithStrict(true))
+	}
+
+	j2, err := r.matrixBuilder.j2Pool.Get()
+	if err != nil {
+		return err
+	}
+	renderFailed := false
+	defer func() {
+		r.matrixBuilder.j2Pool.Put(j2, !renderFailed)
+	}()
+
+	listRenderOpts := append(j2Opts[:len(j2Opts):len(j2Opts)], jinja2.WithGlobals(baseVars))
+	matrixEntries, err := r.matrixBuilder.buildMatrixEntries(ctx, rt, objClient, scrubber, j2, listRenderOpts)
+	if err != nil {
+		renderFailed = true
+		return err
+	}
+	matrixEntries, err = sortMatrixEntries(matrixEntries)
+	if err != nil {
+		return err
+	}
+	matrixEntries, err = r.matrixBuilder.filterMatrixEntries(j2, rt, baseVars, matrixEntries, j2Opts)
+	if err != nil {
+		renderFailed = true
+		return err
+	}
+
+	results := make([]string, 0, len(matrixEntries))
+	for i, matrix := range matrixEntries {
+		vars := buildMatrixVars(baseVars, matrix)
+		renderOpts := append(j2Opts[:len(j2Opts):len(j2Opts)], jinja2.WithGlobals(vars))
+		rendered, err := r.RenderLimits.renderString(j2, mt.Spec.Template, renderOpts...)
+		if err != nil {
+			renderFailed = true
+			return fmt.Errorf("failed to render template for matrix entry %d: %w", i, err)
+		}
+		results = append(results, rendered)
+	}
+
+	err = r.applyTarget(ctx, objClient, mt, strings.Join(results, mt.Spec.Separator))
+	if err != nil {
+		return err
+	}
+
+	mt.Status.MatrixEntries = len(matrixEntries)
+	return nil
+}
+
+// applyTarget writes the result into the target ConfigMap or Secret. The target is owned by the MatrixTextTemplate, so
+// that it gets garbage collected when the MatrixTextTemplate is deleted
+func (r *MatrixTextTemplateReconciler) applyTarget(ctx context.Context, objClient client.Client, mt *templatesv1alpha1.MatrixTextTemplate, result string) error {
+	kind := mt.Spec.Target.Kind
+	if kind == "" {
+		kind = templatesv1alpha1.MatrixTextTemplateTargetConfigMap
+	}
+
+	var o unstructured.Unstructured
+	o.SetAPIVersion("v1")
+	o.SetKind(kind)
+	o.SetNamespace(mt.GetNamespace())
+	o.SetName(mt.Spec.Target.Name)
+
+	switch kind {
+	case templatesv1alpha1.MatrixTextTemplateTargetConfigMap:
+		o.Object["data"] = map[string]any{
+			mt.Spec.Target.Key: result,
+		}
+	case templatesv1alpha1.MatrixTextTemplateTargetSecret:
+		o.Object["data"] = map[string]any{
+			mt.Spec.Target.Key: base64.StdEncoding.EncodeToString([]byte(result)),
+		}
+	default:
+		return fmt.Errorf("invalid target kind %s", kind)
+	}
+
+	err := controllerutil.SetControllerReference(mt, &o, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	err = objClient.Patch(ctx, &o, client.Apply, client.FieldOwner(r.FieldManager), client.ForceOwnership)
+	if err != nil {
+		return fmt.Errorf("failed to apply target %s %s: %w", kind, mt.Spec.Target.Name, err)
+	}
+	return nil
+}
+
+// buildMatrixObjectTemplate builds an in-memory ObjectTemplate that carries everything needed to build the matrix
+func (r *MatrixTextTemplateReconciler) buildMatrixObjectTemplate(mt *templatesv1alpha1.MatrixTextTemplate) *templatesv1alpha1.ObjectTemplate {
+	rt := &templatesv1alpha1.ObjectTemplate{}
+	rt.SetNamespace(mt.GetNamespace())
+	rt.SetName(mt.GetName())
+	// lets matrixTextTemplateEventRecorder record events on the MatrixTextTemplate
+	rt.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: templatesv1alpha1.GroupVersion.String(),
+		Kind:       "MatrixTextTemplate",
+		Name:       mt.GetName(),
+		UID:        mt.GetUID(),
+	}})
+	rt.Spec.Interval = mt.Spec.Interval
+	rt.Spec.ServiceAccountName = mt.Spec.ServiceAccountName
+	rt.Spec.Matrix = mt.Spec.Matrix
+	rt.Spec.MatrixFilter = mt.Spec.MatrixFilter
+	rt.Spec.StrictUndefined = mt.Spec.StrictUndefined
+	rt.Spec.Vars = mt.Spec.Vars
+	return rt
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MatrixTextTemplateReconciler) SetupWithManager(mgr ctrl.Manager, concurrent int) error {
+	r.Manager = mgr
+
+	r.matrixBuilder = &ObjectTemplateReconciler{
+		BaseTemplateReconciler: BaseTemplateReconciler{
+			Client:            r.Client,
+			Manager:           mgr,
+			Scheme:            r.Scheme,
+			FieldManager:      r.FieldManager,
+			AllowedNamespaces: r.AllowedNamespaces,
+		},
+		TmpBaseDir:   r.TmpBaseDir,
+		RenderLimits: r.RenderLimits,
+	}
+	if r.EventRecorder != nil {
+		r.matrixBuilder.EventRecorder = matrixTextTemplateEventRecorder{EventRecorder: r.EventRecorder}
+	}
+
+	// each concurrent reconciliation uses its own Jinja2 instance from the pool
+	r.matrixBuilder.j2Pool = newJinja2Pool(concurrent)
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		r.matrixBuilder.j2Pool.Close()
+		return nil
+	})); err != nil {
+		return err
+	}
+
+	// Index the MatrixTextTemplate by the objects they are for.
+	if err := mgr.GetCache().IndexField(context.TODO(), &templatesv1alpha1.MatrixTextTemplate{}, forMatrixObjectKey,
+		func(object client.Object) []string {
+			return r.matrixBuilder.buildMatrixObjectIndexValues(r.buildMatrixObjectTemplate(object.(*templatesv1alpha1.MatrixTextTemplate)))
+		}); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+	if err := mgr.GetCache().IndexField(context.TODO(), &templatesv1alpha1.MatrixTextTemplate{}, forMatrixObjectListKey,
+		func(object client.Object) []string {
+			return r.matrixBuilder.buildMatrixObjectListIndexValues(r.buildMatrixObjectTemplate(object.(*templatesv1alpha1.MatrixTextTemplate)))
+		}); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&templatesv1alpha1.MatrixTextTemplate{}, r.buildForPredicates()).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: concurrent,
+		}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+
+	return nil
+}
+
+func (r *MatrixTextTemplateReconciler) buildWatchEventHandler(indexField string, buildIndexValue func(obj client.Object) string) handler.EventHandler {
+	return enqueueRequestsFromMapFuncDelayed(func(ctx context.Context, object client.Object) []reconcile.Request {
+		var list templatesv1alpha1.MatrixTextTemplateList
+
+		err := r.List(ctx, &list, client.MatchingFields{
+			indexField: buildIndexValue(object),
+		})
+		if err != nil {
+			return nil
+		}
+		var reqs []reconcile.Request
+		for _, x := range list.Items {
+			if !r.isInShard(&x) {
+				continue
+			}
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: x.GetNamespace(),
+					Name:      x.GetName(),
+				},
+			})
+		}
+		return reqs
+	}, r.WatchDelay)
+}
+
+// matrixTextTemplateEventRecorder records the events of the in-memory ObjectTemplates used to build the matrix on the
+// MatrixTextTemplates that own them
+type matrixTextTemplateEventRecorder struct {
+	record.EventRecorder
+}
+
+func (x matrixTextTemplateEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	x.EventRecorder.Event(x.target(object), eventtype, reason, message)
+}
+
+func (x matrixTextTemplateEventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	x.EventRecorder.Eventf(x.target(object), eventtype, reason, messageFmt, args...)
+}
+
+func (x matrixTextTemplateEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	x.EventRecorder.AnnotatedEventf(x.target(object), annotations, eventtype, reason, messageFmt, args...)
+}
+
+func (x matrixTextTemplateEventRecorder) target(object runtime.Object) runtime.Object {
+	rt, ok := object.(*templatesv1alpha1.ObjectTemplate)
+	if !ok {
+		return object
+	}
+	for _, ref := range rt.GetOwnerReferences() {
+		if ref.Kind != "MatrixTextTemplate" {
+			continue
+		}
+		mt := &templatesv1alpha1.MatrixTextTemplate{}
+		mt.SetNamespace(rt.GetNamespace())
+		mt.SetName(ref.Name)
+		mt.SetUID(ref.UID)
+		return mt
+	}
+	return object
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+)
+
+// errMaxResourcesExceeded is returned when an ObjectTemplate renders more objects than allowed
+var errMaxResourcesExceeded = errors.New("maximum number of resources exceeded")
+
+func isMaxResourcesExceededError(err error) bool {
+	return errors.Is(err, errMaxResourcesExceeded)
+}
+
+// getMaxResources returns the effective maximum number of rendered objects, which is the lower of spec.maxResources
+// and the controller-wide limit. 0 means unlimited
+func (r *ObjectTemplateReconciler) getMaxResources(rt *templatesv1alpha1.ObjectTemplate) int {
+	limit := r.MaxResources
+	if rt.Spec.MaxResources != nil && (limit <= 0 || *rt.Spec.MaxResources < limit) {
+		limit = *rt.Spec.MaxResources
+	}
+	return limit
+}
+
+// checkMaxResources returns an error if count exceeds the effective maximum number of rendered objects
+func (r *ObjectTemplateReconciler) checkMaxResources(rt *templatesv1alpha1.ObjectTemplate, count int) error {
+	limit := r.getMaxResources(rt)
+	if limit <= 0 || count <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w: %d objects were rendered, the maximum is %d", errMaxResourcesExceeded, count, limit)
+}
+
+// checkMaxMatrixResources returns an error if the matrix would render more objects than allowed, so that runaway
+// matrices fail before rendering. Each template without `when` renders at least one object per matrix entry
+func (r *ObjectTemplateReconciler) checkMaxMatrixResources(rt *templatesv1alpha1.ObjectTemplate, matrixCount int) error {
+	limit := r.getMaxResources(rt)
+	if limit <= 0 {
+		return nil
+	}
+	unconditional := 0
+	for _, t := range rt.Spec.Templates {
+		if t.When == "" {
+			unconditional++
+		}
+	}
+	count := matrixCount * unconditional
+	if count <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w: %d matrix entries with %d unconditional templates would render %d objects, the maximum is %d", errMaxResourcesExceeded, matrixCount, unconditional, count, limit)
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsResultSuccess = "success"
+	metricsResultError   = "error"
+)
+
+var (
+	objectTemplateReconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_controller_objecttemplate_reconcile_total",
+		Help: "Total number of ObjectTemplate reconciliations by result.",
+	}, []string{"result"})
+
+	objectTemplateReconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "template_controller_objecttemplate_reconcile_duration_seconds",
+		Help:    "Duration of ObjectTemplate reconciliations in seconds.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 15),
+	})
+
+	objectTemplateAppliedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "template_controller_objecttemplate_applied_resources",
+		Help: "Number of resources currently applied by an ObjectTemplate.",
+	}, []string{"namespace", "name"})
+
+	objectTemplatePrunedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_controller_objecttemplate_pruned_total",
+		Help: "Total number of resources deleted by ObjectTemplate pruning.",
+	}, []string{"namespace", "name"})
+
+	objectTemplateVanishedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_controller_objecttemplate_vanished_total",
+		Help: "Total number of applied resources that were found to be deleted out-of-band.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		objectTemplateReconcileTotal,
+		objectTemplateReconcileDuration,
+		objectTemplateAppliedResources,
+		objectTemplatePrunedTotal,
+		objectTemplateVanishedTotal,
+	)
+}
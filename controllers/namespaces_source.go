@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"context"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var namespaceGVK = corev1.SchemeGroupVersion.WithKind("Namespace")
+
+// buildNamespacesInput lists all namespaces matching the label selector. Namespaces are listed with the controller's
+// own permissions, as listing namespaces requires cluster-wide permissions which tenant service accounts usually
+// don't have. Namespaces that are being deleted or that the controller is not allowed to access are skipped
+func (r *ObjectTemplateReconciler) buildNamespacesInput(ctx context.Context, me *templatesv1alpha1.MatrixEntryNamespaces) ([]any, error) {
+	exclude := func(o *unstructured.Unstructured) (bool, error) {
+		if o.GetDeletionTimestamp() != nil {
+			return true, nil
+		}
+		return r.checkNamespaceAllowed(o.GetName()) != nil, nil
+	}
+	return r.buildObjectListInput(ctx, r.Client, "", namespaceGVK, me.LabelSelector, nil, false, exclude)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"github.com/kluctl/go-jinja2"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// objectRenderer renders all templates of an ObjectTemplate for a single matrix entry. Besides the rendered objects,
+// it returns the apply modes of objects that must not be applied with server-side apply and the namespaces of the
+// templates that rendered the objects
+type objectRenderer interface {
+	render(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, vars map[string]any) ([]*unstructured.Unstructured, map[*unstructured.Unstructured]string, map[*unstructured.Unstructured]string, error)
+}
+
+// rendererFactory builds the objectRenderer used for a single reconciliation
+type rendererFactory func(targetClient client.Client, j2 *jinja2.Jinja2, j2Opts []jinja2.Jinja2Opt, templateConfigMaps map[string]*corev1.ConfigMap) objectRenderer
+
+// jinja2ObjectRenderer is the default objectRenderer, which renders the templates with Jinja2
+type jinja2ObjectRenderer struct {
+	r                  *ObjectTemplateReconciler
+	targetClient       client.Client
+	j2                 *jinja2.Jinja2
+	j2Opts             []jinja2.Jinja2Opt
+	templateConfigMaps map[string]*corev1.ConfigMap
+}
+
+func (x *jinja2ObjectRenderer) render(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, vars map[string]any) ([]*unstructured.Unstructured, map[*unstructured.Unstructured]string, map[*unstructured.Unstructured]string, error) {
+	return x.r.renderTemplates(ctx, x.targetClient, x.j2, rt, vars, x.j2Opts, x.templateConfigMaps)
+}
+
+// buildRenderer returns the renderer for a single reconciliation. Tests can replace it via newRenderer
+func (r *ObjectTemplateReconciler) buildRenderer(targetClient client.Client, j2 *jinja2.Jinja2, j2Opts []jinja2.Jinja2Opt, templateConfigMaps map[string]*corev1.ConfigMap) objectRenderer {
+	if r.newRenderer != nil {
+		return r.newRenderer(targetClient, j2, j2Opts, templateConfigMaps)
+	}
+	return &jinja2ObjectRenderer{
+		r:                  r,
+		targetClient:       targetClient,
+		j2:                 j2,
+		j2Opts:             j2Opts,
+		templateConfigMaps: templateConfigMaps,
+	}
+}
+
+// needsJinja2 returns true if building the matrix or waiting for objects evaluates Jinja2 expressions, which is
+// independent of the renderer used for the templates
+func needsJinja2(rt *templatesv1alpha1.ObjectTemplate) bool {
+	if rt.Spec.MatrixFilter != "" || (rt.Spec.Wait != nil && len(rt.Spec.HealthChecks) != 0) {
+		return true
+	}
+	for _, me := range rt.Spec.Matrix {
+		if me.List != nil || me.DistinctBy != "" || (me.Object != nil && me.Object.IsTemplated()) {
+			return true
+		}
+		if me.ObjectList != nil && me.ObjectList.ExcludeWhen != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// forceReconcile runs a single reconciliation of rt, regardless of suspend, sharding and skipUnchangedSources. The
+// status is only modified in-memory. Tests use it together with newClientForObjects, newRenderer, newClusterInfo and
+// Synchronous to reconcile without a manager, cluster or Jinja2 process
+func (r *ObjectTemplateReconciler) forceReconcile(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate) error {
+	return r.doReconcile(ctx, rt)
+}
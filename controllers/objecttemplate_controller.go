@@ -17,38 +17,121 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"github.com/kluctl/go-jinja2"
+	ssh_pool "github.com/kluctl/kluctl/v2/pkg/git/ssh-pool"
 	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
 	"io"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 )
 
 const forMatrixObjectKey = "spec.matrix.object.ref"
+const forMatrixObjectListKey = "spec.matrix.objectList"
+const forConfigMapRefKey = "spec.configMapRefs"
+
+// waitPollInterval is the interval in which applied objects are checked for readiness when waiting is enabled
+const waitPollInterval = 2 * time.Second
+
+// defaultApplyConcurrency is used when ApplyConcurrency is not set
+const defaultApplyConcurrency = 10
+
+// applyRetryInitialBackoff is the delay before the first retry when applying an object failed with a transient error
+const applyRetryInitialBackoff = 500 * time.Millisecond
+
+// statusPatchBackoff is used to retry failed status updates at the end of a reconciliation
+var statusPatchBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 200 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
+// maxErrorBackoff is the maximum delay until the next reconciliation after consecutive failures. If the configured
+// interval is larger, the interval is used instead
+const maxErrorBackoff = 10 * time.Minute
 
 // ObjectTemplateReconciler reconciles a ObjectTemplate object
 type ObjectTemplateReconciler struct {
 	BaseTemplateReconciler
+
+	EventRecorder record.EventRecorder
+
+	// EnableCustomJinja2Filters allows ObjectTemplates to load custom Jinja2 filters from ConfigMaps. Custom filters
+	// are Python code that is executed inside the controller process
+	EnableCustomJinja2Filters bool
+
+	// ApplyConcurrency limits the number of objects that are applied or deleted in parallel per reconciliation
+	ApplyConcurrency int
+
+	// KindPolicy optionally restricts the kinds of objects that can be applied
+	KindPolicy *KindPolicy
+
+	// TmpBaseDir is used to store mirrors of Git repositories used as matrix inputs
+	TmpBaseDir string
+
+	// RenderLimits optionally restricts the resources used when rendering templates
+	RenderLimits *RenderLimits
+
+	// MaxResources limits the number of objects a single ObjectTemplate may render. 0 disables the limit
+	MaxResources int
+
+	j2Pool *jinja2Pool
+
+	httpCache   httpSourceCache
+	sshPool     ssh_pool.SshPool
+	renderCache renderCache
+
+	clusterInfoCache clusterInfoCache
+
+	// Synchronous disables concurrency inside reconciliations, so that rendering, applying and deleting happens in a
+	// deterministic order. Only meant to be used in tests
+	Synchronous bool
+
+	// newRenderer optionally replaces the Jinja2 based renderer, e.g. with a fake renderer in tests
+	newRenderer rendererFactory
+
+	// newClusterInfo optionally replaces the discovery of the target cluster, e.g. in tests or when rendering offline
+	newClusterInfo func(restConfig *rest.Config) (map[string]any, error)
+
+	// Applier sends rendered objects to the cluster. Defaults to server-side apply and patches via the target client
+	// if not set before calling SetupWithManager
+	Applier Applier
 }
 
 //+kubebuilder:rbac:groups=templates.kluctl.io,resources=objecttemplates,verbs=get;list;watch;create;update;patch;delete
@@ -58,6 +141,7 @@ type ObjectTemplateReconciler struct {
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;impersonate
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile a resource
 func (r *ObjectTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
@@ -74,6 +158,11 @@ func (r *ObjectTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return
 	}
 
+	// the template might have been moved to another shard in the meantime
+	if !r.isInShard(&rt) {
+		return
+	}
+
 	// Add our finalizer if it does not exist
 	if !controllerutil.ContainsFinalizer(&rt, templatesv1alpha1.ObjectTemplateFinalizer) {
 		patch := client.MergeFrom(rt.DeepCopy())
@@ -92,53 +181,179 @@ func (r *ObjectTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	// Return early if the object is suspended.
 	if rt.Spec.Suspend {
 		logger.Info("Reconciliation is suspended for this object")
-		return ctrl.Result{}, nil
+		patch := client.MergeFrom(rt.DeepCopy())
+		apimeta.SetStatusCondition(&rt.Status.Conditions, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: rt.GetGeneration(),
+			Reason:             "Suspended",
+			Message:            "Reconciliation is suspended",
+		})
+		err = r.Status().Patch(ctx, &rt, patch, SubResourceFieldOwner(r.FieldManager))
+		return
 	}
 
 	for _, me := range rt.Spec.Matrix {
-		if me.Object != nil {
-			gvk, err2 := me.Object.Ref.GroupVersionKind()
+		ref := r.buildMatrixEntryRef(me)
+		if ref != nil {
+			gvk, err2 := ref.GroupVersionKind()
+			if err2 != nil {
+				err = err2
+				return
+			}
+			err = r.addWatchForKind(ctx, gvk, forMatrixObjectKey, r.buildWatchEventHandler(forMatrixObjectKey, BuildObjectIndexValue))
+			if err != nil {
+				return
+			}
+			if me.Object != nil && me.Object.IsTemplated() {
+				// templated refs are indexed by kind and namespace, see buildMatrixObjectListIndexValues
+				err = r.addWatchForKind(ctx, gvk, forMatrixObjectListKey, r.buildWatchEventHandler(forMatrixObjectListKey, BuildObjectKindNamespaceIndexValue))
+				if err != nil {
+					return
+				}
+			}
+		}
+		if me.ObjectList != nil {
+			gvk, err2 := me.ObjectList.GroupVersionKind()
 			if err2 != nil {
 				err = err2
 				return
 			}
-			err = r.addWatchForKind(ctx, gvk, forMatrixObjectKey, r.buildWatchEventHandler(forMatrixObjectKey))
+			err = r.addWatchForKind(ctx, gvk, forMatrixObjectListKey, r.buildWatchEventHandler(forMatrixObjectListKey, BuildObjectKindNamespaceIndexValue))
 			if err != nil {
 				return
 			}
 		}
+		if me.Namespaces != nil {
+			err = r.addWatchForKind(ctx, namespaceGVK, forMatrixObjectListKey, r.buildWatchEventHandler(forMatrixObjectListKey, BuildObjectKindNamespaceIndexValue))
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if len(r.buildConfigMapRefs(&rt)) != 0 {
+		err = r.addWatchForKind(ctx, schema.GroupVersionKind{
+			Version: "v1",
+			Kind:    "ConfigMap",
+		}, forConfigMapRefKey, r.buildWatchEventHandler(forConfigMapRefKey, BuildObjectIndexValue))
+		if err != nil {
+			return
+		}
+	}
+
+	// watches are registered above, so that source changes still enqueue the template after a restart
+	unchanged, err2 := r.sourcesUnchanged(ctx, &rt)
+	if err2 != nil {
+		logger.V(1).Info("Failed to check sources for changes, doing a full reconciliation", "err", err2)
+	} else if unchanged {
+		logger.V(1).Info("Sources unchanged since last successful reconciliation, skipping")
+		result.RequeueAfter = r.jitterInterval(rt.Spec.Interval.Duration)
+		return
 	}
 
 	patch := client.MergeFrom(rt.DeepCopy())
-	err = r.doReconcile(ctx, &rt)
+	startTime := time.Now()
+	reconcileRequest := getReconcileRequest(&rt)
+	if reconcileRequest != "" && reconcileRequest != rt.Status.LastHandledReconcileAt {
+		logger.Info("Reconciliation requested via annotation", "requestedAt", reconcileRequest)
+	}
+	reconcileCtx := ctx
+	if rt.Spec.Timeout != nil && rt.Spec.Timeout.Duration > 0 {
+		var cancel context.CancelFunc
+		reconcileCtx, cancel = context.WithTimeout(ctx, rt.Spec.Timeout.Duration)
+		defer cancel()
+	}
+	err = r.doReconcile(reconcileCtx, &rt)
+	objectTemplateReconcileDuration.Observe(time.Since(startTime).Seconds())
+	objectTemplateAppliedResources.WithLabelValues(rt.Namespace, rt.Name).Set(float64(len(rt.Status.AppliedResources)))
 	if err != nil {
-		c := metav1.Condition{
-			Type:               "Ready",
-			Status:             metav1.ConditionFalse,
-			ObservedGeneration: rt.GetGeneration(),
-			Reason:             "Error",
-			Message:            err.Error(),
+		objectTemplateReconcileTotal.WithLabelValues(metricsResultError).Inc()
+	} else {
+		objectTemplateReconcileTotal.WithLabelValues(metricsResultSuccess).Inc()
+	}
+	now := metav1.NewTime(startTime)
+	rt.Status.LastReconcileTime = &now
+	if err == nil {
+		rt.Status.LastSuccessfulReconcileTime = &now
+	}
+	rt.Status.LastHandledReconcileAt = reconcileRequest
+	c := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: rt.GetGeneration(),
+		Reason:             "Success",
+		Message:            "Success",
+	}
+	if err != nil {
+		rt.Status.FailureCount++
+		c.Status = metav1.ConditionFalse
+		c.Reason = readyReasonForError(err)
+		c.Message = err.Error()
+		if reconcileCtx.Err() == context.DeadlineExceeded {
+			c.Reason = "Timeout"
+			c.Message = fmt.Sprintf("Reconciliation timed out after %s: %s", rt.Spec.Timeout.Duration.String(), err.Error())
 		}
-		apimeta.SetStatusCondition(&rt.Status.Conditions, c)
 	} else {
-		c := metav1.Condition{
-			Type:               "Ready",
-			Status:             metav1.ConditionTrue,
-			ObservedGeneration: rt.GetGeneration(),
-			Reason:             "Success",
-			Message:            "Success",
+		rt.Status.FailureCount = 0
+		if rt.Spec.DryRun {
+			c.Reason = "DryRun"
+			c.Message = "Dry-run succeeded, no changes were applied"
 		}
-		apimeta.SetStatusCondition(&rt.Status.Conditions, c)
 	}
-	err = r.Status().Patch(ctx, &rt, patch, SubResourceFieldOwner(r.FieldManager))
+	apimeta.SetStatusCondition(&rt.Status.Conditions, c)
+	err = r.patchStatusWithRetries(ctx, &rt, patch)
 	if err != nil {
+		logger.Error(err, "Failed to update status, applied resources might not be tracked correctly")
 		return
 	}
 
-	result.RequeueAfter = rt.Spec.Interval.Duration
+	result.RequeueAfter = r.jitterInterval(calcErrorBackoff(rt.Spec.Interval.Duration, rt.Status.FailureCount))
 	return
 }
 
+// readyReasonForError returns the reason of the Ready condition for a failed reconciliation
+func readyReasonForError(err error) string {
+	switch {
+	case isRenderLimitExceededError(err):
+		return "RenderLimitExceeded"
+	case isMaxResourcesExceededError(err):
+		return "MaxResourcesExceeded"
+	case isKindNotInstalledError(err):
+		return "KindNotInstalled"
+	case isKindNotAllowedError(err):
+		return "KindNotAllowed"
+	default:
+		return "Error"
+	}
+}
+
+// patchStatusWithRetries retries patching the status on failures. The status contains the bookkeeping of applied
+// resources, losing it would cause objects to not be pruned later. Merge patches don't conflict, so retrying with
+// the same patch is safe
+func (r *ObjectTemplateReconciler) patchStatusWithRetries(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, patch client.Patch) error {
+	return retry.OnError(statusPatchBackoff, func(err error) bool {
+		return ctx.Err() == nil && !errors.IsNotFound(err)
+	}, func() error {
+		return r.Status().Patch(ctx, rt, patch, SubResourceFieldOwner(r.FieldManager))
+	})
+}
+
+// calcErrorBackoff doubles the interval for every consecutive failure after the first one, capped at maxErrorBackoff
+func calcErrorBackoff(interval time.Duration, failureCount int) time.Duration {
+	if failureCount <= 1 || interval >= maxErrorBackoff {
+		return interval
+	}
+	backoff := interval
+	for i := 1; i < failureCount && backoff < maxErrorBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxErrorBackoff {
+		backoff = maxErrorBackoff
+	}
+	return backoff
+}
+
 func (r *ObjectTemplateReconciler) multiplyMatrix(matrix []map[string]any, key string, newElems []any) []map[string]any {
 	var newMatrix []map[string]any
 
@@ -156,288 +371,2230 @@ func (r *ObjectTemplateReconciler) multiplyMatrix(matrix []map[string]any, key s
 	return newMatrix
 }
 
-func (r *ObjectTemplateReconciler) buildMatrixEntries(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, client client.Client) ([]map[string]any, error) {
-	var err error
-	var matrixEntries []map[string]any
-	matrixEntries = append(matrixEntries, map[string]any{})
-
-	for _, me := range rt.Spec.Matrix {
-		var elems []any
-		if me.Object != nil {
-			elems, err = r.buildObjectInput(ctx, client, rt.GetNamespace(), me.Object.Ref, me.Object.JsonPath, me.Object.ExpandLists, false)
-			if err != nil {
-				return nil, err
-			}
-		} else if me.List != nil {
-			for _, le := range me.List {
-				var e any
-				err := yaml.Unmarshal(le.Raw, &e)
-				if err != nil {
-					return nil, err
-				}
-				elems = append(elems, e)
-			}
-		} else {
-			return nil, fmt.Errorf("missing matrix value")
-		}
+// getTargetClient returns the client used to apply rendered objects. If spec.kubeConfig is set, a client for the
+// remote cluster is built from the referenced Secret, which is loaded via objClient. The returned rest config is only
+// set for remote clusters
+func (r *ObjectTemplateReconciler) getTargetClient(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) (client.Client, *rest.Config, error) {
+	if rt.Spec.KubeConfig == nil {
+		return objClient, nil, nil
+	}
 
-		matrixEntries = r.multiplyMatrix(matrixEntries, me.Name, elems)
+	ref := rt.Spec.KubeConfig.SecretRef
+	key := ref.Key
+	if key == "" {
+		key = "value"
 	}
-	return matrixEntries, nil
-}
 
-func (r *ObjectTemplateReconciler) doReconcile(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate) error {
-	baseVars, err := r.buildBaseVars(rt, "objectTemplate")
+	var secret corev1.Secret
+	err := objClient.Get(ctx, types.NamespacedName{Namespace: rt.GetNamespace(), Name: ref.Name}, &secret)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to get kubeconfig Secret %s: %w", ref.Name, err)
+	}
+	kubeConfig, ok := secret.Data[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("key %s not found in kubeconfig Secret %s", key, ref.Name)
 	}
 
-	j2, err := NewJinja2()
+	rawConfig, err := clientcmd.Load(kubeConfig)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to load kubeconfig from Secret %s: %w", ref.Name, err)
+	}
+	// exec plugins and auth providers would allow to run arbitrary commands inside the controller
+	for name, ai := range rawConfig.AuthInfos {
+		if ai.Exec != nil || ai.AuthProvider != nil {
+			return nil, nil, fmt.Errorf("user %s of kubeconfig Secret %s uses an exec plugin or auth provider, which is not allowed", name, ref.Name)
+		}
 	}
-	defer j2.Close()
-
-	var allResources []*unstructured.Unstructured
-	var errs *multierror.Error
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
 
-	objClient, err := r.getClientForObjects(rt.Spec.ServiceAccountName, rt.GetNamespace())
+	restConfig, err := clientcmd.NewDefaultClientConfig(*rawConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to load kubeconfig from Secret %s: %w", ref.Name, err)
 	}
 
-	matrixEntries, err := r.buildMatrixEntries(ctx, rt, objClient)
+	c, err := client.New(restConfig, client.Options{})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return c, restConfig, nil
+}
 
-	wg.Add(len(matrixEntries))
-	for _, matrix := range matrixEntries {
-		matrix := matrix
-		go func() {
-			defer wg.Done()
-			vars := runtime.DeepCopyJSON(baseVars)
-			MergeMap(vars, map[string]interface{}{
-				"matrix": matrix,
-			})
-
-			resources, err := r.renderTemplates(j2, rt, vars)
-			mutex.Lock()
-			defer mutex.Unlock()
-			if err != nil {
-				errs = multierror.Append(errs, err)
-				return
-			}
-
-			allResources = append(allResources, resources...)
-		}()
-	}
-	wg.Wait()
-	if errs != nil {
-		return errs
+// filterMatrixEntries drops all matrix entries for which spec.matrixFilter evaluates to a falsy value
+func (r *ObjectTemplateReconciler) filterMatrixEntries(j2 *jinja2.Jinja2, rt *templatesv1alpha1.ObjectTemplate, baseVars map[string]any, matrixEntries []map[string]any, j2Opts []jinja2.Jinja2Opt) ([]map[string]any, error) {
+	if rt.Spec.MatrixFilter == "" {
+		return matrixEntries, nil
 	}
 
-	for _, x := range allResources {
-		rm, err := r.Client.RESTMapper().RESTMapping(x.GroupVersionKind().GroupKind(), x.GroupVersionKind().Version)
+	var ret []map[string]any
+	for i, matrix := range matrixEntries {
+		vars := buildMatrixVars(baseVars, matrix)
+		ok, err := r.RenderLimits.evalCondition(j2, rt.Spec.MatrixFilter, vars, j2Opts...)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to evaluate matrixFilter for matrix entry %d: %w", i, err)
 		}
-		if rm.Scope.Name() == apimeta.RESTScopeNameNamespace && x.GetNamespace() == "" {
-			x.SetNamespace(rt.Namespace)
+		if ok {
+			ret = append(ret, matrix)
 		}
 	}
+	return ret, nil
+}
 
-	newAppliedResources := map[templatesv1alpha1.ObjectRef]templatesv1alpha1.AppliedResourceInfo{}
-	for _, n := range rt.Status.AppliedResources {
-		newAppliedResources[n.Ref.WithoutVersion()] = n
+// buildMatrixEntries builds the multiplied matrix. j2 and listRenderOpts are used to render the elements of list
+// entries
+func (r *ObjectTemplateReconciler) buildMatrixEntries(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, client client.Client, scrubber *secretScrubber, j2 *jinja2.Jinja2, listRenderOpts []jinja2.Jinja2Opt) ([]map[string]any, error) {
+	// MatrixTextTemplates are not covered by the spec validation of ObjectTemplates
+	if errs := templatesv1alpha1.ValidateMatrix(rt.Spec.Matrix, field.NewPath("spec", "matrix")); len(errs) != 0 {
+		return nil, errs.ToAggregate()
 	}
 
-	wg.Add(len(allResources))
-	for _, resource := range allResources {
-		resource := resource
-
-		go func() {
-			defer wg.Done()
-			err := r.applyRenderedObject(ctx, objClient, resource)
-			mutex.Lock()
-			defer mutex.Unlock()
+	var matrixEntries []map[string]any
+	matrixEntries = append(matrixEntries, map[string]any{})
 
-			ari := templatesv1alpha1.AppliedResourceInfo{
-				Ref:     templatesv1alpha1.ObjectRefFromObject(resource),
-				Success: true,
+	for _, me := range rt.Spec.Matrix {
+		if me.Object != nil && me.Object.IsTemplated() {
+			// the referenced object depends on the previous matrix entries, so it must be loaded per partial row
+			var newMatrixEntries []map[string]any
+			for _, m := range matrixEntries {
+				obj, err := r.renderMatrixEntryObject(j2, me, m, listRenderOpts)
+				if err != nil {
+					return nil, err
+				}
+				elems, err := r.buildMatrixObjectElems(ctx, client, rt, obj)
+				if err != nil {
+					if r.isMissingOptionalInput(rt, me, err) {
+						newMatrixEntries = append(newMatrixEntries, m)
+						continue
+					}
+					return nil, err
+				}
+				elems, err = applyMatrixDefaults(me, elems)
+				if err != nil {
+					return nil, err
+				}
+				elems, err = distinctMatrixElems(j2, r.RenderLimits, me, elems, listRenderOpts)
+				if err != nil {
+					return nil, err
+				}
+				newMatrixEntries = append(newMatrixEntries, r.multiplyMatrix([]map[string]any{m}, me.Name, elems)...)
 			}
+			matrixEntries = newMatrixEntries
+			continue
+		}
 
-			if err != nil {
-				ari.Success = false
-				ari.Error = err.Error()
-				errs = multierror.Append(errs, err)
+		elems, err := r.buildMatrixEntryElems(ctx, rt, client, scrubber, j2, listRenderOpts, me)
+		if err != nil {
+			if r.isMissingOptionalInput(rt, me, err) {
+				// leave out the whole dimension, so that the other inputs still result in matrix entries
+				continue
 			}
-			newAppliedResources[ari.Ref.WithoutVersion()] = ari
-		}()
-	}
-	wg.Wait()
-
-	defer func() {
-		rt.Status.AppliedResources = make([]templatesv1alpha1.AppliedResourceInfo, 0, len(newAppliedResources))
-		for _, ari := range newAppliedResources {
-			rt.Status.AppliedResources = append(rt.Status.AppliedResources, ari)
+			return nil, err
 		}
-		sort.Slice(rt.Status.AppliedResources, func(i, j int) bool {
-			return rt.Status.AppliedResources[i].Ref.String() < rt.Status.AppliedResources[j].Ref.String()
-		})
-	}()
 
-	if errs != nil {
-		return errs
+		elems, err = applyMatrixDefaults(me, elems)
+		if err != nil {
+			return nil, err
+		}
+		elems, err = distinctMatrixElems(j2, r.RenderLimits, me, elems, listRenderOpts)
+		if err != nil {
+			return nil, err
+		}
+		matrixEntries = r.multiplyMatrix(matrixEntries, me.Name, elems)
 	}
+	return matrixEntries, nil
+}
 
-	err = r.prune(ctx, objClient, rt, allResources, newAppliedResources)
+// applyMatrixDefaults deep-merges the defaults of the matrix entry under each element that is a map. Values of the
+// elements take precedence over the defaults
+func applyMatrixDefaults(me *templatesv1alpha1.MatrixEntry, elems []any) ([]any, error) {
+	if me.Defaults == nil {
+		return elems, nil
+	}
+	var defaults map[string]any
+	err := json.Unmarshal(me.Defaults.Raw, &defaults)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to parse defaults of matrix entry %s: %w", me.Name, err)
 	}
 
-	return nil
-}
-
-func (r *ObjectTemplateReconciler) prune(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, allResources []*unstructured.Unstructured, appliedResources map[templatesv1alpha1.ObjectRef]templatesv1alpha1.AppliedResourceInfo) error {
-	logger := log.FromContext(ctx)
-
-	if !rt.Spec.Prune {
-		return nil
+	ret := make([]any, 0, len(elems))
+	for _, e := range elems {
+		m, ok := e.(map[string]any)
+		if !ok {
+			ret = append(ret, e)
+			continue
+		}
+		merged := runtime.DeepCopyJSON(defaults)
+		MergeMap(merged, m)
+		ret = append(ret, merged)
 	}
+	return ret, nil
+}
 
-	var errs *multierror.Error
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-
-	existingRefs := map[templatesv1alpha1.ObjectRef]templatesv1alpha1.ObjectRef{}
-	for _, resource := range allResources {
-		ref := templatesv1alpha1.ObjectRefFromObject(resource)
-		existingRefs[ref.WithoutVersion()] = ref
+// distinctMatrixElems removes elements for which the distinctBy expression results in a key that was already seen.
+// The first occurrence is kept, so the result only depends on the (stable) order of the loaded elements
+func distinctMatrixElems(j2 *jinja2.Jinja2, limits *RenderLimits, me *templatesv1alpha1.MatrixEntry, elems []any, renderOpts []jinja2.Jinja2Opt) ([]any, error) {
+	if me.DistinctBy == "" {
+		return elems, nil
 	}
 
-	var deleted []templatesv1alpha1.ObjectRef
-	for _, ari := range appliedResources {
-		ari := ari
-		if _, ok := existingRefs[ari.Ref.WithoutVersion()]; ok {
+	seen := map[string]bool{}
+	ret := make([]any, 0, len(elems))
+	for i, e := range elems {
+		key, err := limits.evalString(j2, me.DistinctBy, map[string]any{"element": e}, renderOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate distinctBy for element %d of matrix entry %s: %w", i, me.Name, err)
+		}
+		if seen[key] {
 			continue
 		}
+		seen[key] = true
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
 
-		logger.Info("Deleting object", "ref", ari.Ref)
-
-		gvk, err := ari.Ref.GroupVersionKind()
+// sortMatrixEntries sorts the multiplied matrix by the JSON representation of its entries, so that the order does not
+// depend on the order in which inputs were loaded
+func sortMatrixEntries(matrixEntries []map[string]any) ([]map[string]any, error) {
+	type entryWithKey struct {
+		entry map[string]any
+		key   string
+	}
+	sorted := make([]entryWithKey, 0, len(matrixEntries))
+	for i, m := range matrixEntries {
+		b, err := json.Marshal(m)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to serialize matrix entry %d: %w", i, err)
 		}
-		m := metav1.PartialObjectMetadata{}
-		m.SetGroupVersionKind(gvk)
-		m.SetNamespace(ari.Ref.Namespace)
-		m.SetName(ari.Ref.Name)
+		sorted = append(sorted, entryWithKey{entry: m, key: string(b)})
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].key < sorted[j].key
+	})
+	ret := make([]map[string]any, 0, len(sorted))
+	for _, x := range sorted {
+		ret = append(ret, x.entry)
+	}
+	return ret, nil
+}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := objClient.Delete(ctx, &m)
-			mutex.Lock()
-			defer mutex.Unlock()
+// buildMatrixEntryElems loads the elements of a single matrix entry that does not depend on other matrix entries
+func (r *ObjectTemplateReconciler) buildMatrixEntryElems(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, client client.Client, scrubber *secretScrubber, j2 *jinja2.Jinja2, listRenderOpts []jinja2.Jinja2Opt, me *templatesv1alpha1.MatrixEntry) ([]any, error) {
+	var err error
+	var elems []any
+	if me.Object != nil {
+		elems, err = r.buildMatrixObjectElems(ctx, client, rt, me.Object)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.ObjectList != nil {
+		gvk, err := me.ObjectList.GroupVersionKind()
+		if err != nil {
+			return nil, err
+		}
+		exclude := buildObjectListExcludeFunc(j2, r.RenderLimits, me.ObjectList, listRenderOpts)
+		elems, err = r.buildObjectListInput(ctx, client, r.buildObjectListNamespace(rt, me.ObjectList), gvk, me.ObjectList.LabelSelector, me.ObjectList.JsonPath, me.ObjectList.ExpandLists, exclude)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.Namespaces != nil {
+		elems, err = r.buildNamespacesInput(ctx, me.Namespaces)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.ConfigMap != nil {
+		ref := r.buildMatrixEntryRef(me)
+		elems, err = r.buildConfigMapInput(ctx, client, rt.GetNamespace(), *ref, me.ConfigMap.Key, me.ConfigMap.ExpandLists)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.Secret != nil {
+		ref := r.buildMatrixEntryRef(me)
+		elems, err = r.buildSecretInput(ctx, client, rt.GetNamespace(), *ref, me.Secret.Key, me.Secret.ExpandLists, scrubber)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.HTTP != nil {
+		elems, err = r.buildHttpInput(ctx, client, rt, me.HTTP, scrubber)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.Range != nil {
+		elems, err = buildRangeInput(me.Range)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.Git != nil {
+		elems, err = r.buildGitInput(ctx, client, rt, me.Git)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.ObjectTemplate != nil {
+		ref := r.buildMatrixEntryRef(me)
+		elems, err = r.buildObjectTemplateInput(ctx, client, rt, *ref, me.ObjectTemplate)
+		if err != nil {
+			return nil, err
+		}
+	} else if me.List != nil {
+		for i, le := range me.List {
+			rendered, err := r.RenderLimits.renderString(j2, string(le.Raw), listRenderOpts...)
 			if err != nil {
-				if errors.IsNotFound(err) {
-					err = nil
-				} else {
-					errs = multierror.Append(errs, err)
-				}
+				return nil, fmt.Errorf("failed to render element %d of matrix list %s: %w", i, me.Name, err)
 			}
-			if err == nil {
-				deleted = append(deleted, ari.Ref)
+			var e any
+			err = yaml.Unmarshal([]byte(rendered), &e)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse rendered element %d of matrix list %s: %w", i, me.Name, err)
 			}
-		}()
+			if me.ElementType != "" {
+				e, err = coerceMatrixElem(e, me.ElementType)
+				if err != nil {
+					return nil, fmt.Errorf("failed to coerce element %d of matrix list %s to %s: %w", i, me.Name, me.ElementType, err)
+				}
+			}
+			elems = append(elems, e)
+		}
+	} else {
+		return nil, fmt.Errorf("missing matrix value")
 	}
-	wg.Wait()
+	return elems, nil
+}
 
-	for _, ref := range deleted {
-		delete(appliedResources, ref.WithoutVersion())
+// isMissingOptionalInput returns true if err was caused by a missing object or a kind that is not installed, referenced
+// by an optional matrix entry. In that case, a warning is recorded in the status
+func (r *ObjectTemplateReconciler) isMissingOptionalInput(rt *templatesv1alpha1.ObjectTemplate, me *templatesv1alpha1.MatrixEntry, err error) bool {
+	if !me.Optional || (!errors.IsNotFound(err) && !isKindNotInstalledError(err)) {
+		return false
 	}
-
-	return errs.ErrorOrNil()
+	msg := fmt.Sprintf("optional matrix input %s is missing: %s", me.Name, err.Error())
+	rt.Status.Warnings = append(rt.Status.Warnings, msg)
+	r.recordEvent(rt, corev1.EventTypeWarning, "OptionalInputMissing", "%s", msg)
+	return true
 }
 
-func (r *ObjectTemplateReconciler) applyRenderedObject(ctx context.Context, objClient client.Client, rendered *unstructured.Unstructured) error {
-	logger := log.FromContext(ctx)
+func (r *ObjectTemplateReconciler) loadDataKey(ctx context.Context, client client.Client, objNamespace string, ref templatesv1alpha1.ObjectRef, key string) (string, error) {
+	jp := fmt.Sprintf("data[\"%s\"]", key)
+	elems, err := r.buildObjectInput(ctx, client, objNamespace, ref, &jp, false, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to load key %s from %s: %w", key, ref.String(), err)
+	}
+	x, ok := elems[0].(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected error. Element is not a string")
+	}
+	return x, nil
+}
 
-	var origMeta metav1.PartialObjectMetadata
-	origObjFound := false
+func (r *ObjectTemplateReconciler) buildMatrixObjectElems(ctx context.Context, client client.Client, rt *templatesv1alpha1.ObjectTemplate, me *templatesv1alpha1.MatrixEntryObject) ([]any, error) {
+	var elems []any
+	var err error
+	if me.Engine == templatesv1alpha1.PathEngineJmesPath {
+		elems, err = r.buildObjectInputJmesPath(ctx, client, rt.GetNamespace(), me)
+	} else {
+		elems, err = r.buildObjectInput(ctx, client, rt.GetNamespace(), me.Ref, me.JsonPath, me.ExpandLists, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if me.FailOnEmpty && len(elems) == 0 {
+		path := "<none>"
+		if me.JsonPath != nil {
+			path = *me.JsonPath
+		}
+		return nil, fmt.Errorf("path %s did not return any results for object %s", path, me.Ref.String())
+	}
+	if me.IncludeKeys {
+		elems = expandMapElements(elems)
+	}
+	if len(me.Fields) == 0 {
+		return elems, nil
+	}
+	return extractMatrixFields(elems, me.Fields, me.Engine)
+}
+
+// expandMapElements replaces each map with one element per map entry, in the form {key: ..., value: ...}. Entries are
+// sorted by key. Other elements are kept as is
+func expandMapElements(elems []any) []any {
+	ret := make([]any, 0, len(elems))
+	for _, e := range elems {
+		m, ok := e.(map[string]any)
+		if !ok {
+			ret = append(ret, e)
+			continue
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ret = append(ret, map[string]any{
+				"key":   k,
+				"value": m[k],
+			})
+		}
+	}
+	return ret
+}
+
+// extractMatrixFields turns each element into a map with one entry per field. Fields that don't match anything are
+// set to nil, fields with multiple matches are set to the list of matches
+func extractMatrixFields(elems []any, fields map[string]string, engine string) ([]any, error) {
+	ret := make([]any, 0, len(elems))
+	for _, e := range elems {
+		m := map[string]any{}
+		for name, expr := range fields {
+			expr := expr
+			var results []any
+			var err error
+			if engine == templatesv1alpha1.PathEngineJmesPath {
+				results, err = applyJmesPath(e, &expr)
+			} else {
+				results, err = applyJsonPath(e, &expr)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract field %s: %w", name, err)
+			}
+			switch len(results) {
+			case 0:
+				m[name] = nil
+			case 1:
+				m[name] = results[0]
+			default:
+				m[name] = results
+			}
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+// renderMatrixEntryObject renders the namespace, name and jsonPath of an object matrix entry with access to the
+// partial matrix row built from the previous matrix entries. renderOpts must contain the base vars, so that the
+// expressions can also refer to the ObjectTemplate itself
+func (r *ObjectTemplateReconciler) renderMatrixEntryObject(j2 *jinja2.Jinja2, me *templatesv1alpha1.MatrixEntry, partialRow map[string]any, renderOpts []jinja2.Jinja2Opt) (*templatesv1alpha1.MatrixEntryObject, error) {
+	opts := append(renderOpts[:len(renderOpts):len(renderOpts)], jinja2.WithGlobal("matrix", partialRow))
+
+	ret := me.Object.DeepCopy()
+	render := func(s *string, fieldName string) error {
+		x, err := r.RenderLimits.renderString(j2, *s, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to render %s of matrix entry %s: %w", fieldName, me.Name, err)
+		}
+		*s = x
+		return nil
+	}
+	if err := render(&ret.Ref.Namespace, "namespace"); err != nil {
+		return nil, err
+	}
+	if err := render(&ret.Ref.Name, "name"); err != nil {
+		return nil, err
+	}
+	if ret.JsonPath != nil {
+		if err := render(ret.JsonPath, "jsonPath"); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (r *ObjectTemplateReconciler) buildObjectInputJmesPath(ctx context.Context, client client.Client, objNamespace string, me *templatesv1alpha1.MatrixEntryObject) ([]any, error) {
+	elems, err := r.buildObjectInput(ctx, client, objNamespace, me.Ref, nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	results, err := applyJmesPath(elems[0], me.JsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate JMESPath expression on %s: %w", me.Ref.String(), err)
+	}
+	return expandListElements(results, me.ExpandLists), nil
+}
+
+// buildObjectTemplateInput loads the refs of all successfully applied resources of another ObjectTemplate, or the result
+// of the given jsonPath
+func (r *ObjectTemplateReconciler) buildObjectTemplateInput(ctx context.Context, client client.Client, rt *templatesv1alpha1.ObjectTemplate, ref templatesv1alpha1.ObjectRef, me *templatesv1alpha1.MatrixEntryObjectTemplate) ([]any, error) {
+	// the in-memory ObjectTemplates built for MatrixTextTemplates have no UID and share the name of a different kind,
+	// so these can never reference themselves
+	if rt.GetUID() != "" && (ref.Namespace == "" || ref.Namespace == rt.Namespace) && ref.Name == rt.Name {
+		// this would cause an endless loop of reconciliations, as each status update triggers another one
+		return nil, fmt.Errorf("ObjectTemplate %s can not use itself as matrix input", rt.Name)
+	}
+
+	if me.JsonPath != nil {
+		return r.buildObjectInput(ctx, client, rt.GetNamespace(), ref, me.JsonPath, me.ExpandLists, false)
+	}
+
+	jsonPath := "status.appliedResources"
+	results, err := r.buildObjectInput(ctx, client, rt.GetNamespace(), ref, &jsonPath, true, false)
+	if err != nil {
+		return nil, err
+	}
+	var elems []any
+	for _, x := range results {
+		m, ok := x.(map[string]any)
+		if !ok {
+			continue
+		}
+		if success, _ := m["success"].(bool); !success {
+			continue
+		}
+		if ref, ok := m["ref"]; ok {
+			elems = append(elems, ref)
+		}
+	}
+	return elems, nil
+}
+
+// buildRangeInput behaves like Python's range(). Empty ranges result in no elements
+func buildRangeInput(rng *templatesv1alpha1.MatrixEntryRange) ([]any, error) {
+	if rng.Step == 0 {
+		return nil, fmt.Errorf("range step must not be 0")
+	}
+
+	var ret []any
+	for i := rng.Start; (rng.Step > 0 && i < rng.Stop) || (rng.Step < 0 && i > rng.Stop); i += rng.Step {
+		ret = append(ret, i)
+	}
+	return ret, nil
+}
+
+func (r *ObjectTemplateReconciler) buildConfigMapInput(ctx context.Context, client client.Client, objNamespace string, ref templatesv1alpha1.ObjectRef, key string, expandLists bool) ([]any, error) {
+	x, err := r.loadDataKey(ctx, client, objNamespace, ref, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	err = yaml.Unmarshal([]byte(x), &v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s from %s: %w", key, ref.String(), err)
+	}
+
+	return expandListElements([]any{v}, expandLists), nil
+}
+
+func (r *ObjectTemplateReconciler) buildSecretInput(ctx context.Context, client client.Client, objNamespace string, ref templatesv1alpha1.ObjectRef, key string, expandLists bool, scrubber *secretScrubber) ([]any, error) {
+	x, err := r.loadDataKey(ctx, client, objNamespace, ref, key)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key %s from %s: %w", key, ref.String(), err)
+	}
+	scrubber.AddString(string(decoded))
+
+	var v any
+	err = yaml.Unmarshal(decoded, &v)
+	if err != nil {
+		// don't include the original error as it might contain parts of the secret value
+		return nil, fmt.Errorf("failed to parse key %s from %s", key, ref.String())
+	}
+	scrubber.AddValue(v)
+
+	return expandListElements([]any{v}, expandLists), nil
+}
+
+func (r *ObjectTemplateReconciler) buildHttpInput(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, me *templatesv1alpha1.MatrixEntryHTTP, scrubber *secretScrubber) ([]any, error) {
+	headers := map[string]string{}
+	if me.HeadersSecretRef != nil {
+		var secret corev1.Secret
+		err := r.checkNamespaceAllowed(rt.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+		err = objClient.Get(ctx, types.NamespacedName{Namespace: rt.GetNamespace(), Name: me.HeadersSecretRef.Name}, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get headers secret %s: %w", me.HeadersSecretRef.Name, err)
+		}
+		for k, v := range secret.Data {
+			headers[k] = string(v)
+			scrubber.AddString(string(v))
+		}
+	}
+
+	cacheKey := buildHttpSourceCacheKey(me.URL, headers)
+	body, ok := r.httpCache.get(cacheKey)
+	if !ok {
+		var err error
+		body, err = fetchHttpSource(ctx, me.URL, headers, me.Timeout.Duration)
+		if err != nil {
+			return nil, err
+		}
+		r.httpCache.set(cacheKey, body, rt.Spec.Interval.Duration)
+	}
+
+	var v any
+	err := json.Unmarshal(body, &v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response from %s as JSON: %w", me.URL, err)
+	}
+
+	results, err := applyJsonPath(v, me.JsonPath)
+	if err != nil {
+		return nil, err
+	}
+	return expandListElements(results, me.ExpandLists), nil
+}
+
+// loadJinja2Filters returns the render options for all custom filters and the resourceVersion of the filters ConfigMap
+func (r *ObjectTemplateReconciler) loadJinja2Filters(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) ([]jinja2.Jinja2Opt, string, error) {
+	if !r.EnableCustomJinja2Filters {
+		return nil, "", fmt.Errorf("custom Jinja2 filters are disabled, the controller must be started with --enable-custom-jinja2-filters")
+	}
+
+	err := r.checkNamespaceAllowed(rt.GetNamespace())
+	if err != nil {
+		return nil, "", err
+	}
+
+	var cm corev1.ConfigMap
+	err = objClient.Get(ctx, types.NamespacedName{Namespace: rt.GetNamespace(), Name: rt.Spec.FiltersConfigMapRef.Name}, &cm)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get filters ConfigMap %s: %w", rt.Spec.FiltersConfigMapRef.Name, err)
+	}
+
+	var names []string
+	for k := range cm.Data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var opts []jinja2.Jinja2Opt
+	for _, name := range names {
+		opts = append(opts, jinja2.WithFilter(name, cm.Data[name]))
+	}
+	return opts, cm.ResourceVersion, nil
+}
+
+// loadJinja2Includes writes all keys of the includes ConfigMap into a temporary directory, which is then used as
+// Jinja2 search dir. The caller must remove the directory when done
+func (r *ObjectTemplateReconciler) loadJinja2Includes(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) (string, string, error) {
+	err := r.checkNamespaceAllowed(rt.GetNamespace())
+	if err != nil {
+		return "", "", err
+	}
+
+	var cm corev1.ConfigMap
+	err = objClient.Get(ctx, types.NamespacedName{Namespace: rt.GetNamespace(), Name: rt.Spec.IncludesConfigMapRef.Name}, &cm)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get includes ConfigMap %s: %w", rt.Spec.IncludesConfigMapRef.Name, err)
+	}
+
+	err = os.MkdirAll(r.TmpBaseDir, 0o700)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := os.MkdirTemp(r.TmpBaseDir, "includes-")
+	if err != nil {
+		return "", "", err
+	}
+	for k, v := range cm.Data {
+		if k == "." || k == ".." || filepath.Base(k) != k {
+			_ = os.RemoveAll(dir)
+			return "", "", fmt.Errorf("invalid key %s in includes ConfigMap %s", k, rt.Spec.IncludesConfigMapRef.Name)
+		}
+		err = os.WriteFile(filepath.Join(dir, k), []byte(v), 0o600)
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return "", "", err
+		}
+	}
+	return dir, cm.ResourceVersion, nil
+}
+
+func (r *ObjectTemplateReconciler) doReconcile(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate) (retErr error) {
+	// the logger already contains the namespace and name of the ObjectTemplate
+	logger := log.FromContext(ctx)
+
+	// registered before scrubbing, so that the conditions are set with the scrubbed error
+	phases := newReconcilePhases()
+	defer func() {
+		phases.setConditions(rt, retErr)
+	}()
+
+	// values loaded from secrets must never end up in the status of the ObjectTemplate
+	scrubber := &secretScrubber{}
+	defer func() {
+		retErr = scrubber.ScrubError(retErr)
+		for i := range rt.Status.AppliedResources {
+			rt.Status.AppliedResources[i].Error = scrubber.Scrub(rt.Status.AppliedResources[i].Error)
+		}
+		for i := range rt.Status.Warnings {
+			rt.Status.Warnings[i] = scrubber.Scrub(rt.Status.Warnings[i])
+		}
+	}()
+
+	rt.Status.Warnings = nil
+	if !rt.Spec.DetectDrift {
+		rt.Status.DriftedResources = nil
+		apimeta.RemoveStatusCondition(&rt.Status.Conditions, "Drifted")
+	}
+
+	if errs := rt.Spec.Validate(field.NewPath("spec")); len(errs) != 0 {
+		return errs.ToAggregate()
+	}
+
+	if rt.Spec.FieldManager != "" && strings.IndexFunc(rt.Spec.FieldManager, unicode.IsSpace) != -1 {
+		return fmt.Errorf("invalid fieldManager '%s', must not contain whitespace", rt.Spec.FieldManager)
+	}
+
+	baseVars, err := r.buildBaseVars(rt, "objectTemplate")
+	if err != nil {
+		return err
+	}
+
+	objClient, err := r.getClientForObjects(rt.Spec.ServiceAccountName, rt.GetNamespace())
+	if err != nil {
+		return err
+	}
+
+	// sources are loaded before the inputs, so that changes in between are detected by the next reconciliation
+	rt.Status.ObservedSources = nil
+	if canTrackSources(rt) {
+		observedSources, err := r.loadObservedSources(ctx, objClient, rt)
+		if err != nil {
+			return err
+		}
+		// pending prunes must be finished by a later reconciliation, so it must not be skipped
+		defer func() {
+			if retErr == nil && !phases.prunePending {
+				rt.Status.ObservedSources = observedSources
+			}
+		}()
+	}
+
+	if rt.Spec.Inventory != nil {
+		// the inventory is also updated when the reconciliation fails, as objects might have been applied or pruned
+		defer func() {
+			err := r.storeInventory(ctx, objClient, rt)
+			if err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+	}
+
+	if len(rt.Spec.Vars) != 0 {
+		vars, err := r.buildVars(ctx, objClient, rt, scrubber)
+		if err != nil {
+			return err
+		}
+		baseVars = mergeBaseVars(vars, baseVars)
+	}
+	// targetClient is used for everything that modifies or inspects rendered objects
+	targetClient, targetRestConfig, err := r.getTargetClient(ctx, objClient, rt)
+	if err != nil {
+		return err
+	}
+	clusterInfo, err := r.getClusterInfo(targetRestConfig)
+	if err != nil {
+		return fmt.Errorf("failed to discover target cluster: %w", err)
+	}
+	mergeClusterInfo(baseVars, clusterInfo)
+
+	// dry-run mode must not modify status.appliedResources
+	var vanished map[templatesv1alpha1.ObjectRef]bool
+	if !rt.Spec.DryRun {
+		vanished = r.removeVanishedResources(ctx, targetClient, rt)
+	}
+
+	// j2Opts are passed to every render call, as the Jinja2 instance is shared with other reconciliations
+	var j2Opts []jinja2.Jinja2Opt
+	if rt.Spec.StrictUndefined {
+		j2Opts = append(j2Opts, jinja2.WithStrict(true))
+	}
+	// custom filters and includes are not part of the generation, so their versions must be part of the render cache key
+	var configMapVersions []string
+	if rt.Spec.FiltersConfigMapRef != nil {
+		filterOpts, rv, err := r.loadJinja2Filters(ctx, objClient, rt)
+		if err != nil {
+			return err
+		}
+		j2Opts = append(j2Opts, filterOpts...)
+		configMapVersions = append(configMapVersions, rv)
+	}
+	if rt.Spec.IncludesConfigMapRef != nil {
+		includesDir, rv, err := r.loadJinja2Includes(ctx, objClient, rt)
+		if err != nil {
+			return err
+		}
+		configMapVersions = append(configMapVersions, rv)
+		defer os.RemoveAll(includesDir)
+		j2Opts = append(j2Opts, jinja2.WithSearchDir(includesDir))
+	}
+
+	templateConfigMaps, err := r.loadTemplateConfigMaps(ctx, objClient, rt)
+	if err != nil {
+		return err
+	}
+
+	// fake renderers used in tests don't need a Jinja2 instance, unless the matrix or the health checks use expressions
+	var j2 *jinja2.Jinja2
+	renderFailed := false
+	if r.newRenderer == nil || needsJinja2(rt) {
+		j2, err = r.j2Pool.Get()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			r.j2Pool.Put(j2, !renderFailed)
+		}()
+	}
+
+	var allResources []*unstructured.Unstructured
+	var errs *multierror.Error
+	var mutex sync.Mutex
+
+	listRenderOpts := append(j2Opts[:len(j2Opts):len(j2Opts)], jinja2.WithGlobals(baseVars))
+	matrixEntries, err := r.buildMatrixEntries(ctx, rt, objClient, scrubber, j2, listRenderOpts)
+	if err != nil {
+		renderFailed = true
+		return err
+	}
+	matrixEntries, err = sortMatrixEntries(matrixEntries)
+	if err != nil {
+		return err
+	}
+	rt.Status.MatrixCount = len(matrixEntries)
+	matrixEntries, err = r.filterMatrixEntries(j2, rt, baseVars, matrixEntries, j2Opts)
+	if err != nil {
+		renderFailed = true
+		return err
+	}
+	phases.matrixCount = len(matrixEntries)
+	logger.V(1).Info("Built matrix", "matrixCount", rt.Status.MatrixCount, "filteredMatrixCount", len(matrixEntries))
+
+	err = r.checkMaxMatrixResources(rt, len(matrixEntries))
+	if err != nil {
+		r.recordEvent(rt, corev1.EventTypeWarning, "MaxResourcesExceeded", "%s", err.Error())
+		return err
+	}
+
+	var renderCacheKey string
+	if canCacheRenderedObjects(rt) {
+		renderCacheKey, err = buildRenderCacheKey(rt, baseVars, matrixEntries, templateConfigMaps, configMapVersions)
+		if err != nil {
+			return err
+		}
+	} else {
+		r.renderCache.remove(client.ObjectKeyFromObject(rt))
+	}
+
+	// results are stored per matrix entry to keep the order of rendered objects deterministic
+	resourcesByMatrix := make([][]*unstructured.Unstructured, len(matrixEntries))
+	applyModes := map[*unstructured.Unstructured]string{}
+	templateNamespaces := map[*unstructured.Unstructured]string{}
+
+	cached := false
+	if renderCacheKey != "" {
+		if c, m, n, ok := r.renderCache.get(client.ObjectKeyFromObject(rt), renderCacheKey); ok {
+			resourcesByMatrix, applyModes, templateNamespaces, cached = c, m, n, true
+			logger.V(1).Info("Using cached rendered objects")
+		}
+	}
+
+	toRender := matrixEntries
+	if cached {
+		toRender = nil
+	}
+	renderer := r.buildRenderer(targetClient, j2, j2Opts, templateConfigMaps)
+	renderRunner := newParallelRunner(0, r.Synchronous)
+	for i, matrix := range toRender {
+		i := i
+		matrix := matrix
+		renderRunner.run(func() {
+			ctx := log.IntoContext(ctx, logger.WithValues("matrixIndex", i))
+			vars := buildMatrixVars(baseVars, matrix)
+			vars["matrixIndex"] = int64(i)
+			vars["matrixCount"] = int64(len(matrixEntries))
+
+			resources, modes, namespaces, err := renderer.render(ctx, rt, vars)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				renderFailed = true
+				errs = multierror.Append(errs, err)
+				return
+			}
+			for x, mode := range modes {
+				applyModes[x] = mode
+			}
+			for x, ns := range namespaces {
+				templateNamespaces[x] = ns
+			}
+
+			resourcesByMatrix[i] = resources
+		})
+	}
+	renderRunner.wait()
+	if errs != nil {
+		// we can't know which objects would have been rendered by the failed matrix entries, so we must neither
+		// apply a partial result nor prune anything. Returning early also leaves status.appliedResources untouched,
+		// except for the removal of vanished objects
+		return errs
+	}
+	if renderCacheKey != "" && !cached {
+		r.renderCache.put(client.ObjectKeyFromObject(rt), renderCacheKey, resourcesByMatrix, applyModes, templateNamespaces)
+	}
+
+	matrixIndexes := map[*unstructured.Unstructured]int{}
+	for i, resources := range resourcesByMatrix {
+		for _, x := range resources {
+			matrixIndexes[x] = i
+		}
+		allResources = append(allResources, resources...)
+	}
+
+	for _, x := range allResources {
+		err = normalizeRenderedSecret(x, scrubber)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateRenderedObjects(allResources)
+	if err != nil {
+		return err
+	}
+	err = r.KindPolicy.checkObjects(allResources)
+	if err != nil {
+		r.recordEvent(rt, corev1.EventTypeWarning, "KindNotAllowed", "%s", err.Error())
+		return err
+	}
+	if rt.Spec.ValidateSchema {
+		err = validateObjectsSchema(ctx, targetClient, allResources)
+		if err != nil {
+			return err
+		}
+	}
+
+	// rendered objects usually share only a handful of kinds, so we avoid asking the REST mapper for each object
+	restMappings := map[schema.GroupVersionKind]*apimeta.RESTMapping{}
+	crdMappings := buildRenderedCRDMappings(allResources)
+	for _, x := range allResources {
+		gvk := x.GroupVersionKind()
+		rm, ok := restMappings[gvk]
+		if !ok {
+			rm, err = targetClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				// the CRD might be part of the rendered objects and not applied yet, in which case the RESTMapper
+				// fails with different errors depending on whether the API group already exists
+				crdMapping, ok := crdMappings[gvk.GroupKind()]
+				if !ok {
+					return fmt.Errorf("failed to determine scope of %s: %w", gvk.String(), err)
+				}
+				rm = crdMapping
+			}
+			restMappings[gvk] = rm
+		}
+		if rm.Scope.Name() == apimeta.RESTScopeNameNamespace {
+			if rt.Spec.TargetNamespace != "" {
+				x.SetNamespace(rt.Spec.TargetNamespace)
+			} else if x.GetNamespace() == "" {
+				if ns, ok := templateNamespaces[x]; ok {
+					x.SetNamespace(ns)
+				} else {
+					x.SetNamespace(rt.Namespace)
+				}
+			}
+		} else if x.GetNamespace() != "" {
+			// cluster-scoped objects must be applied without a namespace
+			x.SetNamespace("")
+		}
+		err = r.checkNamespaceAllowed(x.GetNamespace())
+		if err != nil {
+			return err
+		}
+		if rt.Spec.CommonMetadata != nil {
+			applyCommonMetadata(rt.Spec.CommonMetadata, x)
+		}
+		if rt.Spec.SetOwnerReferences && canSetOwnerReference(rt, rm, x, getApplyMode(applyModes, x)) {
+			setOwnerReference(rt, x)
+		}
+		err = excludeFields(rt, x)
+		if err != nil {
+			return err
+		}
+	}
+
+	allResources, err = deduplicateRenderedObjects(allResources, matrixIndexes)
+	if err != nil {
+		return err
+	}
+	rt.Status.RenderedResourceCount = len(allResources)
+	err = r.checkMaxResources(rt, len(allResources))
+	if err != nil {
+		r.recordEvent(rt, corev1.EventTypeWarning, "MaxResourcesExceeded", "%s", err.Error())
+		return err
+	}
+	logger.V(1).Info("Rendered objects", "count", len(allResources))
+
+	if rt.Spec.Debug != nil && rt.Spec.Debug.StoreRenderedOutput {
+		err = r.storeRenderedOutput(ctx, objClient, rt, allResources, scrubber)
+		if err != nil {
+			return err
+		}
+	}
+
+	waves, err := groupByApplyWave(allResources)
+	if err != nil {
+		return err
+	}
+
+	phases.renderedCount = len(allResources)
+	phases.current = conditionApplied
+	if rt.Spec.DryRun {
+		phases.dryRun = true
+		return r.dryRun(ctx, targetClient, rt, allResources, applyModes)
+	}
+	rt.Status.DryRunResults = nil
+
+	drifted := map[templatesv1alpha1.ObjectRef]bool{}
+	if rt.Spec.DetectDrift {
+		r.detectDrift(ctx, targetClient, rt, allResources, applyModes, vanished)
+		for _, x := range rt.Status.DriftedResources {
+			drifted[x.Ref.WithoutVersion()] = true
+		}
+	}
+
+	newAppliedResources := map[templatesv1alpha1.ObjectRef]templatesv1alpha1.AppliedResourceInfo{}
+	for _, n := range rt.Status.AppliedResources {
+		newAppliedResources[n.Ref.WithoutVersion()] = n
+	}
+
+	var waitDeadline time.Time
+	if rt.Spec.Wait != nil {
+		waitDeadline = time.Now().Add(rt.Spec.Wait.Timeout.Duration)
+	}
+
+	// waves are applied one after another, objects inside a single wave are applied concurrently
+	runner := r.newApplyRunner()
+	for _, wave := range waves {
+		for _, resource := range wave {
+			resource := resource
+
+			runner.run(func() {
+				ctx := log.IntoContext(ctx, logger.WithValues(
+					"targetGVK", resource.GroupVersionKind().String(),
+					"targetNamespace", resource.GetNamespace(),
+					"targetName", resource.GetName(),
+				))
+				applyMode := getApplyMode(applyModes, resource)
+				ref := templatesv1alpha1.ObjectRefFromObject(resource)
+				hash, hashErr := r.buildAppliedHash(rt, resource, applyMode)
+
+				// skip applying if the rendered object did not change since the last successful apply, unless it drifted
+				mutex.Lock()
+				prev, hasPrev := newAppliedResources[ref.WithoutVersion()]
+				mutex.Unlock()
+				unchangedResourceVersion := ""
+				if hashErr == nil && hasPrev && prev.Success && prev.Hash == hash && !drifted[ref.WithoutVersion()] {
+					unchangedResourceVersion = prev.ResourceVersion
+				}
+
+				result, err := r.applyRenderedObjectWithRetries(ctx, targetClient, rt, resource, applyMode, unchangedResourceVersion)
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				ari := templatesv1alpha1.AppliedResourceInfo{
+					Ref:       ref,
+					Success:   true,
+					ApplyMode: applyMode,
+				}
+				if usesServerSideApply(applyMode) && getConflictPolicy(rt) == templatesv1alpha1.ConflictPolicyForce {
+					ari.ForceApplied = true
+					ari.ConflictPolicy = templatesv1alpha1.ConflictPolicyForce
+				}
+				if applyMode == templatesv1alpha1.ApplyModeCreateOnly {
+					// remember that we created the object in a previous reconciliation
+					created := result != nil && result.created
+					ari.Created = created || (hasPrev && prev.ApplyMode == templatesv1alpha1.ApplyModeCreateOnly && prev.Created)
+				}
+
+				if err == nil && result.skipped {
+					ari.LastAppliedTime = prev.LastAppliedTime
+					ari.Generation = result.generation
+					ari.ResourceVersion = result.resourceVersion
+					ari.Hash = prev.Hash
+					if prev.ConflictPolicy == templatesv1alpha1.ConflictPolicyIgnore {
+						ari.ConflictPolicy = prev.ConflictPolicy
+						ari.IgnoredFields = prev.IgnoredFields
+					}
+				} else if err == nil {
+					now := metav1.Now()
+					ari.LastAppliedTime = &now
+					ari.Generation = result.generation
+					ari.ResourceVersion = result.resourceVersion
+					if hashErr == nil {
+						ari.Hash = hash
+					}
+					if len(result.ignoredFields) != 0 {
+						ari.ConflictPolicy = templatesv1alpha1.ConflictPolicyIgnore
+						ari.IgnoredFields = result.ignoredFields
+					}
+				} else if hasPrev {
+					// keep the info of the last successful apply, so that stale objects can be detected
+					ari.LastAppliedTime = prev.LastAppliedTime
+					ari.Generation = prev.Generation
+					ari.ResourceVersion = prev.ResourceVersion
+				}
+
+				if err != nil {
+					ari.Success = false
+					ari.Error = err.Error()
+					errs = multierror.Append(errs, err)
+					r.recordEvent(rt, corev1.EventTypeWarning, "ApplyFailed", "Failed to apply %s: %s", eventObjectString(ari.Ref), scrubber.Scrub(err.Error()))
+				}
+				newAppliedResources[ari.Ref.WithoutVersion()] = ari
+			})
+		}
+		runner.wait()
+
+		if errs == nil && isCRD(wave[0]) {
+			// objects in the following waves might depend on the CRDs
+			err = waitForCRDsEstablished(ctx, targetClient, wave)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+
+		if errs == nil && rt.Spec.Wait != nil {
+			err = r.waitForObjects(ctx, targetClient, wave, waitDeadline, buildHealthFunc(j2, rt, j2Opts), newAppliedResources)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+
+		if errs != nil {
+			// later waves might depend on the failed objects
+			break
+		}
+	}
+
+	defer func() {
+		rt.Status.AppliedResources = make([]templatesv1alpha1.AppliedResourceInfo, 0, len(newAppliedResources))
+		for _, ari := range newAppliedResources {
+			rt.Status.AppliedResources = append(rt.Status.AppliedResources, ari)
+		}
+		sort.Slice(rt.Status.AppliedResources, func(i, j int) bool {
+			return rt.Status.AppliedResources[i].Ref.Less(rt.Status.AppliedResources[j].Ref)
+		})
+	}()
+
+	if errs != nil {
+		failed := 0
+		for _, ari := range newAppliedResources {
+			if !ari.Success {
+				failed++
+			}
+		}
+		return fmt.Errorf("%d of %d objects applied successfully: %w", len(allResources)-failed, len(allResources), errs)
+	}
+
+	logger.V(1).Info("Applied objects", "count", len(allResources), "waves", len(waves))
+	rt.Status.InitialApplySucceeded = true
+
+	phases.current = conditionPruned
+	phases.prunedCount, phases.prunePending, err = r.prune(ctx, targetClient, rt, allResources, newAppliedResources)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// removeVanishedResources removes all entries from status.appliedResources for which the object does not exist anymore,
+// e.g. because it was deleted out-of-band. This is recorded separately from pruning. Returns the removed refs (without
+// versions)
+func (r *ObjectTemplateReconciler) removeVanishedResources(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) map[templatesv1alpha1.ObjectRef]bool {
+	logger := log.FromContext(ctx)
+
+	var mutex sync.Mutex
+	vanished := map[templatesv1alpha1.ObjectRef]bool{}
+
+	runner := r.newApplyRunner()
+	for _, ari := range rt.Status.AppliedResources {
+		ari := ari
+		gvk, err := ari.Ref.GroupVersionKind()
+		if err != nil {
+			continue
+		}
+
+		runner.run(func() {
+			m := metav1.PartialObjectMetadata{}
+			m.SetGroupVersionKind(gvk)
+			err := objClient.Get(ctx, types.NamespacedName{Namespace: ari.Ref.Namespace, Name: ari.Ref.Name}, &m)
+			if err == nil {
+				return
+			}
+			if !errors.IsNotFound(err) && !apimeta.IsNoMatchError(err) {
+				// we can't tell if the object still exists, so keep it
+				logger.V(1).Info("Failed to check if applied object still exists", "ref", ari.Ref, "err", err)
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			vanished[ari.Ref.WithoutVersion()] = true
+		})
+	}
+	runner.wait()
+
+	if len(vanished) == 0 {
+		return vanished
+	}
+
+	appliedResources := make([]templatesv1alpha1.AppliedResourceInfo, 0, len(rt.Status.AppliedResources))
+	for _, ari := range rt.Status.AppliedResources {
+		if vanished[ari.Ref.WithoutVersion()] {
+			logger.Info("Applied object vanished", "ref", ari.Ref)
+			r.recordEvent(rt, corev1.EventTypeNormal, "Vanished", "%s does not exist anymore", eventObjectString(ari.Ref))
+			continue
+		}
+		appliedResources = append(appliedResources, ari)
+	}
+	rt.Status.AppliedResources = appliedResources
+	objectTemplateVanishedTotal.WithLabelValues(rt.Namespace, rt.Name).Add(float64(len(vanished)))
+
+	return vanished
+}
+
+// prune deletes applied objects that are not rendered anymore and returns the number of deleted objects. pending is
+// true if objects are left for a later reconciliation, either because their prune grace period is not over yet or
+// because they wait for a previous prune order to disappear
+func (r *ObjectTemplateReconciler) prune(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, allResources []*unstructured.Unstructured, appliedResources map[templatesv1alpha1.ObjectRef]templatesv1alpha1.AppliedResourceInfo) (deletedCount int, pending bool, err error) {
+	logger := log.FromContext(ctx)
+
+	existingRefs := map[templatesv1alpha1.ObjectRef]templatesv1alpha1.ObjectRef{}
+	for _, resource := range allResources {
+		ref := templatesv1alpha1.ObjectRefFromObject(resource)
+		existingRefs[ref.WithoutVersion()] = ref
+	}
+	// onCreate templates are not rendered anymore after the initial apply, so their objects are treated as if they
+	// were still rendered, which keeps them in status.appliedResources and excludes them from orphaning and pruning
+	for k, ari := range appliedResources {
+		if ari.ApplyMode == templatesv1alpha1.ApplyModeOnCreate {
+			existingRefs[k] = ari.Ref
+		}
+	}
+
+	// objects that are rendered again are not orphaned anymore
+	var orphaned []templatesv1alpha1.ObjectRef
+	for _, ref := range rt.Status.OrphanedResources {
+		if _, ok := existingRefs[ref.WithoutVersion()]; !ok {
+			orphaned = append(orphaned, ref)
+		}
+	}
+
+	// patched objects were not created by us, so they are neither orphaned nor pruned when not rendered anymore
+	for k, ari := range appliedResources {
+		if _, ok := existingRefs[ari.Ref.WithoutVersion()]; !ok && !isManagedByTemplate(ari) {
+			delete(appliedResources, k)
+		}
+	}
+
+	// objects that were never applied successfully might not have been created by us (e.g. due to conflicts with
+	// other field managers), so we can't confidently delete them and orphan them instead. Objects for which only the
+	// most recent apply failed keep the lastAppliedTime of their last successful apply and are still pruned. Success
+	// covers entries written before lastAppliedTime was tracked
+	for k, ari := range appliedResources {
+		if _, ok := existingRefs[ari.Ref.WithoutVersion()]; ok {
+			continue
+		}
+		if rt.Spec.Prune && (ari.LastAppliedTime != nil || ari.Success) {
+			continue
+		}
+		logger.Info("Orphaning object", "ref", ari.Ref)
+		orphaned = append(orphaned, ari.Ref)
+		delete(appliedResources, k)
+	}
+
+	sort.Slice(orphaned, func(i, j int) bool {
+		return orphaned[i].Less(orphaned[j])
+	})
+	rt.Status.OrphanedResources = orphaned
+
+	if !rt.Spec.Prune {
+		return 0, false, nil
+	}
+
+	var toPrune []templatesv1alpha1.ObjectRef
+	for k, ari := range appliedResources {
+		if _, ok := existingRefs[ari.Ref.WithoutVersion()]; ok {
+			continue
+		}
+		if !r.isPruneGracePeriodOver(rt, &ari) {
+			logger.V(1).Info("Delaying pruning of object", "ref", ari.Ref, "since", ari.PruneCandidateSince)
+			appliedResources[k] = ari
+			pending = true
+			continue
+		}
+		toPrune = append(toPrune, ari.Ref)
+	}
+	liveObjects, err := r.loadPruneObjects(ctx, objClient, toPrune)
+	if err != nil {
+		return 0, pending, err
+	}
+
+	var deleted []templatesv1alpha1.ObjectRef
+	var retained []templatesv1alpha1.ObjectRef
+	var toDelete []*metav1.PartialObjectMetadata
+	for _, ref := range toPrune {
+		m, ok := liveObjects[ref]
+		if !ok {
+			// already gone
+			deleted = append(deleted, ref)
+			continue
+		}
+		if isPruneDisabled(m) {
+			logger.Info("Orphaning object with disabled pruning", "ref", ref)
+			retained = append(retained, ref)
+			r.recordEvent(rt, corev1.EventTypeNormal, "PruneSkipped", "Not deleting %s as pruning is disabled via annotation", eventObjectString(ref))
+			continue
+		}
+		toDelete = append(toDelete, m)
+	}
+
+	done, pruneErr := r.deleteInPruneOrder(ctx, objClient, toDelete, func(m *metav1.PartialObjectMetadata, err error) {
+		ref := templatesv1alpha1.ObjectRefFromObject(m)
+		if err != nil {
+			return
+		}
+		logger.Info("Deleted object", "ref", ref)
+		deleted = append(deleted, ref)
+		r.recordEvent(rt, corev1.EventTypeNormal, "Pruned", "Deleted %s", eventObjectString(ref))
+	})
+	if pruneErr == nil && !done {
+		// the remaining objects stay in status.appliedResources and are pruned in the next reconciliation
+		logger.Info("Waiting for pruned objects to disappear before pruning the next prune order")
+		pending = true
+	}
+
+	for _, ref := range deleted {
+		delete(appliedResources, ref.WithoutVersion())
+	}
+	if len(retained) != 0 {
+		for _, ref := range retained {
+			delete(appliedResources, ref.WithoutVersion())
+		}
+		rt.Status.OrphanedResources = append(rt.Status.OrphanedResources, retained...)
+		sort.Slice(rt.Status.OrphanedResources, func(i, j int) bool {
+			return rt.Status.OrphanedResources[i].Less(rt.Status.OrphanedResources[j])
+		})
+	}
+	objectTemplatePrunedTotal.WithLabelValues(rt.Namespace, rt.Name).Add(float64(len(deleted)))
+
+	return len(deleted), pending, pruneErr
+}
+
+// isPruneGracePeriodOver returns true if the object, which is not rendered anymore, can be pruned. The first time an
+// object is found to be missing, it is marked as prune candidate and only pruned after spec.pruneGracePeriod passed.
+// Objects that are rendered again lose the mark, as their AppliedResourceInfo is rebuilt when applying them
+func (r *ObjectTemplateReconciler) isPruneGracePeriodOver(rt *templatesv1alpha1.ObjectTemplate, ari *templatesv1alpha1.AppliedResourceInfo) bool {
+	if rt.Spec.PruneGracePeriod == nil || rt.Spec.PruneGracePeriod.Duration <= 0 {
+		return true
+	}
+	if ari.PruneCandidateSince == nil {
+		now := metav1.Now()
+		ari.PruneCandidateSince = &now
+		return false
+	}
+	return time.Since(ari.PruneCandidateSince.Time) >= rt.Spec.PruneGracePeriod.Duration
+}
+
+func (r *ObjectTemplateReconciler) dryRun(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, allResources []*unstructured.Unstructured, applyModes map[*unstructured.Unstructured]string) error {
+	results, err := r.dryRunObjects(ctx, objClient, rt, allResources, applyModes)
+	if err == nil && rt.Spec.DetectDrift {
+		r.updateDriftStatus(rt, results, nil)
+	}
+
+	renderedRefs := map[templatesv1alpha1.ObjectRef]bool{}
+	for _, resource := range allResources {
+		ref := templatesv1alpha1.ObjectRefFromObject(resource)
+		renderedRefs[ref.WithoutVersion()] = true
+	}
+
+	if rt.Spec.Prune {
+		for _, ari := range rt.Status.AppliedResources {
+			if renderedRefs[ari.Ref.WithoutVersion()] || !isManagedByTemplate(ari) {
+				continue
+			}
+			results = append(results, templatesv1alpha1.DryRunResult{
+				Ref:    ari.Ref,
+				Action: templatesv1alpha1.DryRunActionPrune,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Ref.Less(results[j].Ref)
+	})
+	rt.Status.DryRunResults = results
+
+	return err
+}
+
+// dryRunObjects applies all objects with server-side dry-run and compares the results with the live objects
+func (r *ObjectTemplateReconciler) dryRunObjects(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, allResources []*unstructured.Unstructured, applyModes map[*unstructured.Unstructured]string) ([]templatesv1alpha1.DryRunResult, error) {
+	var errs *multierror.Error
+	var mutex sync.Mutex
+
+	var results []templatesv1alpha1.DryRunResult
+
+	runner := r.newApplyRunner()
+	for _, resource := range allResources {
+		resource := resource
+
+		runner.run(func() {
+			result, err := r.dryRunRenderedObject(ctx, objClient, rt, resource, getApplyMode(applyModes, resource))
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				return
+			}
+			results = append(results, *result)
+		})
+	}
+	runner.wait()
+
+	return results, errs.ErrorOrNil()
+}
+
+// detectDrift compares the live objects with the rendered objects before they are applied. Failures are reported in
+// the Drifted condition but don't fail the reconciliation, as e.g. objects of kinds that are created in earlier apply
+// waves can't be dry-run before these are applied
+func (r *ObjectTemplateReconciler) detectDrift(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, allResources []*unstructured.Unstructured, applyModes map[*unstructured.Unstructured]string, vanished map[templatesv1alpha1.ObjectRef]bool) {
+	results, err := r.dryRunObjects(ctx, objClient, rt, allResources, applyModes)
+	if err != nil {
+		rt.Status.DriftedResources = nil
+		apimeta.SetStatusCondition(&rt.Status.Conditions, metav1.Condition{
+			Type:               "Drifted",
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: rt.GetGeneration(),
+			Reason:             "DetectionFailed",
+			Message:            err.Error(),
+		})
+		return
+	}
+	r.updateDriftStatus(rt, results, vanished)
+}
+
+// updateDriftStatus updates status.driftedResources and the Drifted condition from the given dry-run results. Objects
+// that would be created are only considered drifted if they were applied before, which includes objects that were
+// already removed from the status by removeVanishedResources
+func (r *ObjectTemplateReconciler) updateDriftStatus(rt *templatesv1alpha1.ObjectTemplate, results []templatesv1alpha1.DryRunResult, vanished map[templatesv1alpha1.ObjectRef]bool) {
+	applied := map[templatesv1alpha1.ObjectRef]bool{}
+	for _, ari := range rt.Status.AppliedResources {
+		if ari.Success {
+			applied[ari.Ref.WithoutVersion()] = true
+		}
+	}
+	for ref := range vanished {
+		applied[ref] = true
+	}
+
+	var drifted []templatesv1alpha1.DriftedResource
+	for _, x := range results {
+		switch x.Action {
+		case templatesv1alpha1.DryRunActionUpdate:
+			drifted = append(drifted, templatesv1alpha1.DriftedResource{
+				Ref:           x.Ref,
+				ChangedFields: x.ChangedFields,
+			})
+		case templatesv1alpha1.DryRunActionCreate:
+			if applied[x.Ref.WithoutVersion()] {
+				drifted = append(drifted, templatesv1alpha1.DriftedResource{
+					Ref:     x.Ref,
+					Deleted: true,
+				})
+			}
+		}
+	}
+	sort.Slice(drifted, func(i, j int) bool {
+		return drifted[i].Ref.Less(drifted[j].Ref)
+	})
+	rt.Status.DriftedResources = drifted
+
+	c := metav1.Condition{
+		Type:               "Drifted",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: rt.GetGeneration(),
+		Reason:             "NoDrift",
+		Message:            "No drift detected",
+	}
+	if len(drifted) != 0 {
+		var refs []string
+		for _, x := range drifted {
+			refs = append(refs, eventObjectString(x.Ref))
+		}
+		c.Status = metav1.ConditionTrue
+		c.Reason = "DriftDetected"
+		c.Message = fmt.Sprintf("%d objects drifted: %s", len(drifted), strings.Join(refs, ", "))
+	}
+	apimeta.SetStatusCondition(&rt.Status.Conditions, c)
+}
+
+func (r *ObjectTemplateReconciler) dryRunRenderedObject(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, rendered *unstructured.Unstructured, applyMode string) (*templatesv1alpha1.DryRunResult, error) {
+	var orig unstructured.Unstructured
+	origObjFound := false
+	orig.SetGroupVersionKind(rendered.GroupVersionKind())
+
+	err := objClient.Get(ctx, client.ObjectKeyFromObject(rendered), &orig)
+	if err == nil {
+		origObjFound = true
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	x := rendered.DeepCopy()
+	err = r.getApplier().Apply(ctx, objClient, rt, x, applyMode, client.DryRunAll)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &templatesv1alpha1.DryRunResult{
+		Ref: templatesv1alpha1.ObjectRefFromObject(rendered),
+	}
+	if !origObjFound {
+		result.Action = templatesv1alpha1.DryRunActionCreate
+		return result, nil
+	}
+
+	result.ChangedFields, err = DiffObjects(&orig, x)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.ChangedFields) == 0 {
+		result.Action = templatesv1alpha1.DryRunActionUnchanged
+	} else {
+		result.Action = templatesv1alpha1.DryRunActionUpdate
+	}
+	return result, nil
+}
+
+// applyResult describes the outcome of applying a single rendered object
+type applyResult struct {
+	// created is true if the object did not exist before
+	created bool
+	// ignoredFields contains the fields that were left out due to the ignore conflict policy
+	ignoredFields []string
+	// skipped is true if applying was skipped because neither the rendered nor the live object changed
+	skipped bool
+
+	generation      int64
+	resourceVersion string
+}
+
+// buildAppliedHash returns a hash over everything that influences how the object is applied
+func (r *ObjectTemplateReconciler) buildAppliedHash(rt *templatesv1alpha1.ObjectTemplate, rendered *unstructured.Unstructured, applyMode string) (string, error) {
+	b, err := json.Marshal(map[string]any{
+		"object":         rendered.Object,
+		"applyMode":      applyMode,
+		"fieldManager":   r.getObjectFieldManager(rt, rendered),
+		"conflictPolicy": getConflictPolicy(rt),
+	})
+	if err != nil {
+		return "", err
+	}
+	return Sha256Bytes(b), nil
+}
+
+// applyRenderedObject applies the object. Conflicts are handled according to the conflict policy. If
+// unchangedResourceVersion is not empty and matches the resourceVersion of the live object, applying is skipped
+func (r *ObjectTemplateReconciler) applyRenderedObject(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, rendered *unstructured.Unstructured, applyMode string, unchangedResourceVersion string) (*applyResult, error) {
+	logger := log.FromContext(ctx)
+
+	parentCtx := ctx
+	if rt.Spec.ApplyTimeout.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rt.Spec.ApplyTimeout.Duration)
+		defer cancel()
+	}
+
+	var origMeta metav1.PartialObjectMetadata
+	origObjFound := false
 	origMeta.SetGroupVersionKind(rendered.GroupVersionKind())
 
-	err := objClient.Get(ctx, client.ObjectKeyFromObject(rendered), &origMeta)
-	if err == nil {
-		origObjFound = true
+	err := objClient.Get(ctx, client.ObjectKeyFromObject(rendered), &origMeta)
+	if err == nil {
+		origObjFound = true
+	}
+
+	if origObjFound && unchangedResourceVersion != "" && origMeta.GetResourceVersion() == unchangedResourceVersion {
+		logger.V(1).Info("Skipping unchanged object")
+		return &applyResult{
+			skipped:         true,
+			generation:      origMeta.GetGeneration(),
+			resourceVersion: origMeta.GetResourceVersion(),
+		}, nil
+	}
+
+	logger.V(1).Info("Applying object", "applyMode", applyMode, "exists", origObjFound)
+	result := &applyResult{}
+	err = r.getApplier().Apply(ctx, objClient, rt, rendered, applyMode)
+	if err != nil && errors.IsConflict(err) && origObjFound && usesServerSideApply(applyMode) && getConflictPolicy(rt) == templatesv1alpha1.ConflictPolicyIgnore {
+		ignored, err2 := r.removeConflictingFields(ctx, objClient, rt, rendered)
+		if err2 != nil {
+			err = fmt.Errorf("failed to resolve conflicts: %w", err2)
+		} else if len(ignored) != 0 {
+			logger.Info("Ignoring conflicting fields", "fields", ignored)
+			result.ignoredFields = ignored
+			err = r.getApplier().Apply(ctx, objClient, rt, rendered, applyMode)
+		}
+	}
+	if err != nil {
+		if parentCtx.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timed out after %s while applying %s: %w", rt.Spec.ApplyTimeout.Duration.String(), renderedObjectString(rendered), err)
+		}
+		return nil, err
+	}
+
+	ref := templatesv1alpha1.ObjectRefFromObject(rendered)
+	if !origObjFound {
+		logger.Info("Created new object", "ref", ref)
+		r.recordEvent(rt, corev1.EventTypeNormal, "Applied", "Created %s", eventObjectString(ref))
+	} else {
+		if origMeta.GetResourceVersion() != rendered.GetResourceVersion() {
+			logger.Info("Updated existing object", "ref", ref)
+			r.recordEvent(rt, corev1.EventTypeNormal, "Applied", "Updated %s", eventObjectString(ref))
+		}
+	}
+
+	result.created = !origObjFound
+	result.generation = rendered.GetGeneration()
+	result.resourceVersion = rendered.GetResourceVersion()
+	return result, nil
+}
+
+// getFieldManager returns the field manager to use when applying rendered objects
+func (r *ObjectTemplateReconciler) getFieldManager(rt *templatesv1alpha1.ObjectTemplate) string {
+	if rt.Spec.FieldManager != "" {
+		return rt.Spec.FieldManager
+	}
+	return r.FieldManager
+}
+
+// getObjectFieldManager returns the field manager to use for the given rendered object, taking spec.fieldManagerByKind
+// into account
+func (r *ObjectTemplateReconciler) getObjectFieldManager(rt *templatesv1alpha1.ObjectTemplate, x *unstructured.Unstructured) string {
+	if fm, ok := rt.Spec.FieldManagerByKind[x.GroupVersionKind().GroupKind().String()]; ok && fm != "" {
+		return fm
+	}
+	return r.getFieldManager(rt)
+}
+
+func (r *ObjectTemplateReconciler) buildApplyOptions(rt *templatesv1alpha1.ObjectTemplate, x *unstructured.Unstructured) []client.PatchOption {
+	opts := []client.PatchOption{
+		client.FieldOwner(r.getObjectFieldManager(rt, x)),
+	}
+	if getConflictPolicy(rt) == templatesv1alpha1.ConflictPolicyForce {
+		opts = append(opts, client.ForceOwnership)
+	}
+	return opts
+}
+
+func (r *ObjectTemplateReconciler) recordEvent(rt *templatesv1alpha1.ObjectTemplate, eventType string, reason string, messageFmt string, args ...any) {
+	if r.EventRecorder == nil {
+		return
+	}
+	r.EventRecorder.Eventf(rt, eventType, reason, messageFmt, args...)
+}
+
+func eventObjectString(ref templatesv1alpha1.ObjectRef) string {
+	if ref.Namespace != "" {
+		return fmt.Sprintf("%s/%s %s/%s", ref.APIVersion, ref.Kind, ref.Namespace, ref.Name)
+	}
+	return fmt.Sprintf("%s/%s %s", ref.APIVersion, ref.Kind, ref.Name)
+}
+
+// waitForObjects polls the given objects until all of them are ready (kstatus Current) or the deadline is reached.
+// The health of each object is computed by health and recorded in appliedResources.
+func (r *ObjectTemplateReconciler) waitForObjects(ctx context.Context, objClient client.Client, objs []*unstructured.Unstructured, deadline time.Time, health healthFunc, appliedResources map[templatesv1alpha1.ObjectRef]templatesv1alpha1.AppliedResourceInfo) error {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	pending := objs
+	var lastErrs *multierror.Error
+	err := wait.PollUntilContextCancel(ctx, waitPollInterval, true, func(ctx context.Context) (bool, error) {
+		var stillPending []*unstructured.Unstructured
+		lastErrs = nil
+		for _, x := range pending {
+			ref := templatesv1alpha1.ObjectRefFromObject(x)
+			key := ref.WithoutVersion()
+
+			o := &unstructured.Unstructured{}
+			o.SetGroupVersionKind(x.GroupVersionKind())
+			err := objClient.Get(ctx, client.ObjectKeyFromObject(x), o)
+			if err != nil {
+				return false, err
+			}
+			res, err := health(o)
+			if err != nil {
+				return false, err
+			}
+
+			ari := appliedResources[key]
+			ari.Health = res.Status.String()
+			appliedResources[key] = ari
+
+			switch res.Status {
+			case status.CurrentStatus:
+			case status.FailedStatus:
+				return false, fmt.Errorf("%s failed: %s", eventObjectString(ref), res.Message)
+			default:
+				stillPending = append(stillPending, x)
+				lastErrs = multierror.Append(lastErrs, fmt.Errorf("%s is %s: %s", eventObjectString(ref), res.Status, res.Message))
+			}
+		}
+		pending = stillPending
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) && lastErrs != nil {
+			return fmt.Errorf("timed out waiting for objects to become ready: %w", lastErrs)
+		}
+		return err
+	}
+	return nil
+}
+
+// newApplyRunner returns a runner that limits the number of parallel apply/delete operations
+func (r *ObjectTemplateReconciler) newApplyRunner() *parallelRunner {
+	n := r.ApplyConcurrency
+	if n <= 0 {
+		n = defaultApplyConcurrency
+	}
+	return newParallelRunner(n, r.Synchronous)
+}
+
+// applyRenderedObjectWithRetries retries applying the object on transient errors, as configured via
+// spec.applyRetries
+func (r *ObjectTemplateReconciler) applyRenderedObjectWithRetries(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, rendered *unstructured.Unstructured, applyMode string, unchangedResourceVersion string) (*applyResult, error) {
+	backoff := wait.Backoff{
+		Steps:    rt.Spec.ApplyRetries + 1,
+		Duration: applyRetryInitialBackoff,
+		Factor:   2,
+		Jitter:   0.1,
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("not applying %s: %w", renderedObjectString(rendered), err)
+	}
+	var result *applyResult
+	err := retry.OnError(backoff, func(err error) bool {
+		// don't retry when the whole reconciliation got cancelled or timed out
+		return ctx.Err() == nil && isRetriableApplyError(err)
+	}, func() error {
+		var err error
+		result, err = r.applyRenderedObject(ctx, objClient, rt, rendered.DeepCopy(), applyMode, unchangedResourceVersion)
+		return err
+	})
+	return result, err
+}
+
+// isRetriableApplyError returns true for transient errors. Conflicts with other field managers are not retried, as
+// these would fail the same way on every retry
+func isRetriableApplyError(err error) bool {
+	return (errors.IsConflict(err) && !errors.HasStatusCause(err, metav1.CauseTypeFieldManagerConflict)) ||
+		errors.IsServerTimeout(err) ||
+		errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsServiceUnavailable(err) ||
+		errors.IsInternalError(err)
+}
+
+// patchRenderedObject sends the rendered object to the API server according to the apply mode. On success, obj
+// contains the object as returned by the API server
+func (r *ObjectTemplateReconciler) patchRenderedObject(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, applyMode string, opts ...client.PatchOption) error {
+	switch applyMode {
+	case templatesv1alpha1.ApplyModeMerge:
+		b, err := json.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, client.FieldOwner(r.getObjectFieldManager(rt, obj)))
+		err = objClient.Patch(ctx, obj, client.RawPatch(types.StrategicMergePatchType, b), opts...)
+		if errors.IsUnsupportedMediaType(err) {
+			// custom resources do not support strategic merge patches
+			err = objClient.Patch(ctx, obj, client.RawPatch(types.MergePatchType, b), opts...)
+		}
+		return err
+	case templatesv1alpha1.ApplyModeJsonPatch:
+		var live unstructured.Unstructured
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := objClient.Get(ctx, client.ObjectKeyFromObject(obj), &live)
+		if err != nil {
+			return err
+		}
+		patch, err := buildJsonPatch(&live, obj)
+		if err != nil {
+			return err
+		}
+		if patch == nil {
+			obj.Object = live.Object
+			return nil
+		}
+		opts = append(opts, client.FieldOwner(r.getObjectFieldManager(rt, obj)))
+		return objClient.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch), opts...)
+	case templatesv1alpha1.ApplyModeCreateOnly:
+		return r.createRenderedObjectIfMissing(ctx, objClient, rt, obj, opts...)
+	default:
+		return objClient.Patch(ctx, obj, client.Apply, append(r.buildApplyOptions(rt, obj), opts...)...)
+	}
+}
+
+// createRenderedObjectIfMissing creates the object if it does not exist yet. Existing objects are never modified, obj
+// is set to the live object in that case
+func (r *ObjectTemplateReconciler) createRenderedObjectIfMissing(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, opts ...client.PatchOption) error {
+	getLive := func() error {
+		var live unstructured.Unstructured
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := objClient.Get(ctx, client.ObjectKeyFromObject(obj), &live)
+		if err != nil {
+			return err
+		}
+		obj.Object = live.Object
+		return nil
 	}
 
-	err = objClient.Patch(ctx, rendered, client.Apply, client.FieldOwner(r.FieldManager))
-	if err != nil {
+	err := getLive()
+	if err == nil || !errors.IsNotFound(err) {
 		return err
 	}
 
-	if !origObjFound {
-		logger.Info("Created new object", "ref", templatesv1alpha1.ObjectRefFromObject(rendered))
-	} else {
-		if origMeta.GetResourceVersion() != rendered.GetResourceVersion() {
-			logger.Info("Updated existing object", "ref", templatesv1alpha1.ObjectRefFromObject(rendered))
+	var po client.PatchOptions
+	po.ApplyOptions(opts)
+	createOpts := []client.CreateOption{client.FieldOwner(r.getObjectFieldManager(rt, obj))}
+	if len(po.DryRun) != 0 {
+		createOpts = append(createOpts, client.DryRunAll)
+	}
+	err = objClient.Create(ctx, obj, createOpts...)
+	if errors.IsAlreadyExists(err) {
+		// created concurrently by someone else
+		return getLive()
+	}
+	return err
+}
+
+// usesServerSideApply returns true if objects are applied via server-side apply in the given apply mode
+func usesServerSideApply(applyMode string) bool {
+	return applyMode == templatesv1alpha1.ApplyModeApply || applyMode == templatesv1alpha1.ApplyModeOnCreate
+}
+
+func getApplyMode(applyModes map[*unstructured.Unstructured]string, x *unstructured.Unstructured) string {
+	if m, ok := applyModes[x]; ok {
+		return m
+	}
+	return templatesv1alpha1.ApplyModeApply
+}
+
+// canSetOwnerReference returns true if an owner reference pointing to the ObjectTemplate is valid for the given
+// object. Owner references can not point to objects in other namespaces or other clusters, cluster-scoped objects
+// can not be owned by namespaced objects and objects patched via merge/jsonPatch are not owned by us at all
+func canSetOwnerReference(rt *templatesv1alpha1.ObjectTemplate, rm *apimeta.RESTMapping, x *unstructured.Unstructured, applyMode string) bool {
+	return rt.Spec.KubeConfig == nil &&
+		rm.Scope.Name() == apimeta.RESTScopeNameNamespace &&
+		x.GetNamespace() == rt.Namespace &&
+		applyMode == templatesv1alpha1.ApplyModeApply
+}
+
+// setOwnerReference adds a controller owner reference pointing to the ObjectTemplate, replacing any other owner
+// reference that points to the same ObjectTemplate
+func setOwnerReference(rt *templatesv1alpha1.ObjectTemplate, x *unstructured.Unstructured) {
+	isController := true
+	ownerRef := metav1.OwnerReference{
+		APIVersion: templatesv1alpha1.GroupVersion.String(),
+		Kind:       "ObjectTemplate",
+		Name:       rt.Name,
+		UID:        rt.UID,
+		Controller: &isController,
+	}
+
+	ownerRefs := []metav1.OwnerReference{ownerRef}
+	for _, o := range x.GetOwnerReferences() {
+		if o.UID == rt.UID {
+			continue
 		}
+		ownerRefs = append(ownerRefs, o)
 	}
+	x.SetOwnerReferences(ownerRefs)
+}
 
-	return nil
+// applyCommonMetadata adds the common labels and annotations to the object. Labels and annotations that are already
+// set by the template are not overwritten
+func applyCommonMetadata(cm *templatesv1alpha1.CommonMetadata, x *unstructured.Unstructured) {
+	merge := func(common map[string]string, m map[string]string) map[string]string {
+		if len(common) == 0 {
+			return m
+		}
+		if m == nil {
+			m = map[string]string{}
+		}
+		for k, v := range common {
+			if _, ok := m[k]; !ok {
+				m[k] = v
+			}
+		}
+		return m
+	}
+	x.SetLabels(merge(cm.Labels, x.GetLabels()))
+	x.SetAnnotations(merge(cm.Annotations, x.GetAnnotations()))
 }
 
-func (r *ObjectTemplateReconciler) renderTemplates(j2 *jinja2.Jinja2, rt *templatesv1alpha1.ObjectTemplate, vars map[string]any) ([]*unstructured.Unstructured, error) {
-	var ret []*unstructured.Unstructured
+// isManagedByTemplate returns true if the object was created/applied via server-side apply or created via the
+// createOnly mode, which means that it is owned by the ObjectTemplate and may be pruned. Objects applied via the
+// onCreate mode are intentionally left alone after the initial apply and are never pruned
+func isManagedByTemplate(ari templatesv1alpha1.AppliedResourceInfo) bool {
+	if ari.ApplyMode == templatesv1alpha1.ApplyModeCreateOnly {
+		return ari.Created
+	}
+	return ari.ApplyMode == "" || ari.ApplyMode == templatesv1alpha1.ApplyModeApply
+}
+
+// groupByApplyWave groups the objects by the value of the apply-wave annotation. The returned waves are sorted in
+// ascending order. CRDs are always put into a separate wave before all other waves, so that objects of the kinds
+// defined by them can be applied in the same reconciliation.
+func groupByApplyWave(objs []*unstructured.Unstructured) ([][]*unstructured.Unstructured, error) {
+	byWave := map[int][]*unstructured.Unstructured{}
+	var crds []*unstructured.Unstructured
+	var errs *multierror.Error
+	for _, x := range objs {
+		if isCRD(x) {
+			crds = append(crds, x)
+			continue
+		}
+		wave := 0
+		if s, ok := x.GetAnnotations()[templatesv1alpha1.ApplyWaveAnnotation]; ok {
+			w, err := strconv.Atoi(s)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("invalid %s annotation on %s: %w", templatesv1alpha1.ApplyWaveAnnotation, renderedObjectString(x), err))
+				continue
+			}
+			wave = w
+		}
+		byWave[wave] = append(byWave[wave], x)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	var keys []int
+	for k := range byWave {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	ret := make([][]*unstructured.Unstructured, 0, len(keys)+1)
+	if len(crds) != 0 {
+		ret = append(ret, crds)
+	}
+	for _, k := range keys {
+		ret = append(ret, byWave[k])
+	}
+	return ret, nil
+}
+
+// loadTemplateConfigMaps loads all ConfigMaps referenced by templates, keyed by name
+func (r *ObjectTemplateReconciler) loadTemplateConfigMaps(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) (map[string]*corev1.ConfigMap, error) {
+	ret := map[string]*corev1.ConfigMap{}
 	for _, t := range rt.Spec.Templates {
+		if t.ConfigMap == nil {
+			continue
+		}
+		if _, ok := ret[t.ConfigMap.Name]; ok {
+			continue
+		}
+
+		err := r.checkNamespaceAllowed(rt.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+
+		var cm corev1.ConfigMap
+		err = objClient.Get(ctx, types.NamespacedName{Namespace: rt.GetNamespace(), Name: t.ConfigMap.Name}, &cm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get template ConfigMap %s: %w", t.ConfigMap.Name, err)
+		}
+		ret[t.ConfigMap.Name] = &cm
+	}
+	return ret, nil
+}
+
+func (r *ObjectTemplateReconciler) renderRawTemplate(j2 *jinja2.Jinja2, raw string, format string, renderOpts []jinja2.Jinja2Opt) ([]*unstructured.Unstructured, error) {
+	rendered, err := r.RenderLimits.renderString(j2, raw, renderOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rendered) == "" {
+		return nil, nil
+	}
+
+	if format == templatesv1alpha1.TemplateFormatJson {
+		return decodeJsonObjects(rendered)
+	}
+
+	var ret []*unstructured.Unstructured
+	d := yaml.NewYAMLToJSONDecoder(strings.NewReader(rendered))
+	for {
+		var u unstructured.Unstructured
+		err = d.Decode(&u)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		ret = append(ret, &u)
+	}
+	return ret, nil
+}
+
+// decodeJsonObjects decodes a stream of JSON values, each being either a single object or an array of objects
+func decodeJsonObjects(s string) ([]*unstructured.Unstructured, error) {
+	var ret []*unstructured.Unstructured
+	d := json.NewDecoder(strings.NewReader(s))
+	for {
+		var raw json.RawMessage
+		err := d.Decode(&raw)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		items := []json.RawMessage{raw}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) != 0 && trimmed[0] == '[' {
+			items = nil
+			err = json.Unmarshal(raw, &items)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, item := range items {
+			var u unstructured.Unstructured
+			err = u.UnmarshalJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, &u)
+		}
+	}
+	return ret, nil
+}
+
+// renderTemplates renders all templates for a single matrix entry. The returned map contains the apply mode for all
+// objects that must not be applied with server-side apply
+func (r *ObjectTemplateReconciler) renderTemplates(ctx context.Context, targetClient client.Client, j2 *jinja2.Jinja2, rt *templatesv1alpha1.ObjectTemplate, vars map[string]any, j2Opts []jinja2.Jinja2Opt, templateConfigMaps map[string]*corev1.ConfigMap) ([]*unstructured.Unstructured, map[*unstructured.Unstructured]string, map[*unstructured.Unstructured]string, error) {
+	logger := log.FromContext(ctx)
+	renderOpts := append(j2Opts[:len(j2Opts):len(j2Opts)], jinja2.WithGlobals(vars))
+
+	var ret []*unstructured.Unstructured
+	applyModes := map[*unstructured.Unstructured]string{}
+	namespaces := map[*unstructured.Unstructured]string{}
+	for i, t := range rt.Spec.Templates {
+		// rendering can't be interrupted, but we can at least avoid starting new renders
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+		if t.When != "" {
+			ok, err := r.RenderLimits.evalCondition(j2, t.When, vars, j2Opts...)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to evaluate 'when' of template %d: %w", i, err)
+			}
+			if !ok {
+				logger.V(1).Info("Skipping template", "templateIndex", i)
+				continue
+			}
+		}
+		if t.ApplyMode == templatesv1alpha1.ApplyModeOnCreate && rt.Status.InitialApplySucceeded {
+			logger.V(1).Info("Skipping onCreate template after initial apply", "templateIndex", i)
+			continue
+		}
+
+		var namespace string
+		if t.Namespace != "" {
+			var err error
+			namespace, err = r.RenderLimits.evalString(j2, t.Namespace, vars, j2Opts...)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to evaluate 'namespace' of template %d: %w", i, err)
+			}
+			if namespace == "" {
+				return nil, nil, nil, fmt.Errorf("'namespace' of template %d evaluated to an empty string", i)
+			}
+		}
+
+		renderOpts := renderOpts
+		if t.ExistingRef != nil {
+			existing, err := r.loadExistingObject(ctx, targetClient, j2, rt, t.ExistingRef, namespace, renderOpts)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to load existing object of template %d: %w", i, err)
+			}
+			renderOpts = append(renderOpts[:len(renderOpts):len(renderOpts)], jinja2.WithGlobal("existing", existing))
+		}
+
+		start := len(ret)
 		if t.Object != nil {
 			x := t.Object.DeepCopy()
-			_, err := j2.RenderStruct(x, jinja2.WithGlobals(vars))
+			err := r.RenderLimits.renderStruct(j2, x, renderOpts...)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
+			}
+			objs, err := expandListObject(x)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to expand list of template %d: %w", i, err)
 			}
-			ret = append(ret, x)
+			ret = append(ret, objs...)
 		} else if t.Raw != nil {
-			r, err := j2.RenderString(*t.Raw, jinja2.WithGlobals(vars))
+			objs, err := r.renderRawTemplate(j2, *t.Raw, t.Format, renderOpts)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
+			}
+			ret = append(ret, objs...)
+		} else if t.ConfigMap != nil {
+			cm, ok := templateConfigMaps[t.ConfigMap.Name]
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("template ConfigMap %s not loaded", t.ConfigMap.Name)
+			}
+			var keys []string
+			if t.ConfigMap.Key != "" {
+				if _, ok := cm.Data[t.ConfigMap.Key]; !ok {
+					return nil, nil, nil, fmt.Errorf("key %s not found in template ConfigMap %s", t.ConfigMap.Key, t.ConfigMap.Name)
+				}
+				keys = append(keys, t.ConfigMap.Key)
+			} else {
+				for k := range cm.Data {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
 			}
-			d := yaml.NewYAMLToJSONDecoder(strings.NewReader(r))
-			for {
-				var u unstructured.Unstructured
-				err = d.Decode(&u)
+			for _, k := range keys {
+				objs, err := r.renderRawTemplate(j2, cm.Data[k], t.Format, renderOpts)
 				if err != nil {
-					if err == io.EOF {
-						break
-					}
-					return nil, err
+					return nil, nil, nil, fmt.Errorf("failed to render key %s of template ConfigMap %s: %w", k, t.ConfigMap.Name, err)
 				}
-				ret = append(ret, &u)
+				ret = append(ret, objs...)
 			}
 		} else {
-			return nil, fmt.Errorf("no template specified")
+			return nil, nil, nil, fmt.Errorf("no template specified")
+		}
+
+		if namespace != "" {
+			for _, x := range ret[start:] {
+				namespaces[x] = namespace
+			}
+		}
+		if t.ApplyMode != "" && t.ApplyMode != templatesv1alpha1.ApplyModeApply {
+			for _, x := range ret[start:] {
+				applyModes[x] = t.ApplyMode
+			}
+		}
+		logger.V(1).Info("Rendered template", "templateIndex", i, "objects", len(ret)-start)
+	}
+	return ret, applyModes, namespaces, nil
+}
+
+// expandListObject returns the items of x if it is a v1/List wrapper, or x itself otherwise
+func expandListObject(x *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	if x.GetAPIVersion() != "v1" || x.GetKind() != "List" {
+		return []*unstructured.Unstructured{x}, nil
+	}
+	items, ok, err := unstructured.NestedSlice(x.Object, "items")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	ret := make([]*unstructured.Unstructured, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("item %d is not an object", i)
 		}
+		ret = append(ret, &unstructured.Unstructured{Object: m})
 	}
 	return ret, nil
 }
 
+// loadExistingObject renders the namespace and name of the given ref and returns the live object from the target
+// cluster. Returns nil if the object does not exist
+func (r *ObjectTemplateReconciler) loadExistingObject(ctx context.Context, targetClient client.Client, j2 *jinja2.Jinja2, rt *templatesv1alpha1.ObjectTemplate, ref *templatesv1alpha1.ObjectRef, templateNamespace string, renderOpts []jinja2.Jinja2Opt) (map[string]any, error) {
+	gvk, err := ref.GroupVersionKind()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := r.RenderLimits.renderString(j2, ref.Namespace, renderOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render namespace: %w", err)
+	}
+	name, err := r.RenderLimits.renderString(j2, ref.Name, renderOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render name: %w", err)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("name evaluated to an empty string")
+	}
+	if namespace == "" {
+		namespace = templateNamespace
+	}
+	if namespace == "" {
+		namespace = rt.Namespace
+	}
+	err = r.checkNamespaceAllowed(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var live unstructured.Unstructured
+	live.SetGroupVersionKind(gvk)
+	err = targetClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &live)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// managed fields are of no use inside templates and only bloat the variables
+	live.SetManagedFields(nil)
+	return live.Object, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ObjectTemplateReconciler) SetupWithManager(mgr ctrl.Manager, concurrent int) error {
 	r.Manager = mgr
+	if r.Applier == nil {
+		r.Applier = &patchApplier{r: r}
+	}
+
+	// each concurrent reconciliation uses its own Jinja2 instance from the pool
+	r.j2Pool = newJinja2Pool(concurrent)
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		r.j2Pool.Close()
+		return nil
+	})); err != nil {
+		return err
+	}
 
 	// Index the ObjectHandler by the objects they are for.
 	if err := mgr.GetCache().IndexField(context.TODO(), &templatesv1alpha1.ObjectTemplate{}, forMatrixObjectKey,
+		func(object client.Object) []string {
+			return r.buildMatrixObjectIndexValues(object.(*templatesv1alpha1.ObjectTemplate))
+		}); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+	if err := mgr.GetCache().IndexField(context.TODO(), &templatesv1alpha1.ObjectTemplate{}, forMatrixObjectListKey,
+		func(object client.Object) []string {
+			return r.buildMatrixObjectListIndexValues(object.(*templatesv1alpha1.ObjectTemplate))
+		}); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+	if err := mgr.GetCache().IndexField(context.TODO(), &templatesv1alpha1.ObjectTemplate{}, forConfigMapRefKey,
 		func(object client.Object) []string {
 			o := object.(*templatesv1alpha1.ObjectTemplate)
 			var ret []string
-			for _, me := range o.Spec.Matrix {
-				if me.Object != nil {
-					ret = append(ret, BuildRefIndexValue(me.Object.Ref, o.GetNamespace()))
-				}
+			for _, ref := range r.buildConfigMapRefs(o) {
+				ret = append(ret, BuildRefIndexValue(ref, o.GetNamespace()))
 			}
 			return ret
 		}); err != nil {
@@ -445,9 +2602,7 @@ func (r *ObjectTemplateReconciler) SetupWithManager(mgr ctrl.Manager, concurrent
 	}
 
 	c, err := ctrl.NewControllerManagedBy(mgr).
-		For(&templatesv1alpha1.ObjectTemplate{}, builder.WithPredicates(
-			predicate.Or(predicate.GenerationChangedPredicate{}),
-		)).
+		For(&templatesv1alpha1.ObjectTemplate{}, r.buildForPredicates()).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: concurrent,
 		}).
@@ -460,18 +2615,21 @@ func (r *ObjectTemplateReconciler) SetupWithManager(mgr ctrl.Manager, concurrent
 	return nil
 }
 
-func (r *ObjectTemplateReconciler) buildWatchEventHandler(indexField string) handler.EventHandler {
-	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
+func (r *ObjectTemplateReconciler) buildWatchEventHandler(indexField string, buildIndexValue func(obj client.Object) string) handler.EventHandler {
+	return enqueueRequestsFromMapFuncDelayed(func(ctx context.Context, object client.Object) []reconcile.Request {
 		var list templatesv1alpha1.ObjectTemplateList
 
 		err := r.List(context.Background(), &list, client.MatchingFields{
-			indexField: BuildObjectIndexValue(object),
+			indexField: buildIndexValue(object),
 		})
 		if err != nil {
 			return nil
 		}
 		var reqs []reconcile.Request
 		for _, x := range list.Items {
+			if !r.isInShard(&x) {
+				continue
+			}
 			reqs = append(reqs, reconcile.Request{
 				NamespacedName: types.NamespacedName{
 					Namespace: x.GetNamespace(),
@@ -480,11 +2638,19 @@ func (r *ObjectTemplateReconciler) buildWatchEventHandler(indexField string) han
 			})
 		}
 		return reqs
-	})
+	}, r.WatchDelay)
 }
 
 func (r *ObjectTemplateReconciler) finalize(ctx context.Context, obj *templatesv1alpha1.ObjectTemplate) (ctrl.Result, error) {
-	r.doFinalize(ctx, obj)
+	err := r.doFinalize(ctx, obj)
+	if err != nil {
+		// keep the finalizer so that deletion is retried with backoff
+		return ctrl.Result{}, err
+	}
+
+	objectTemplateAppliedResources.DeleteLabelValues(obj.Namespace, obj.Name)
+	r.renderCache.remove(client.ObjectKeyFromObject(obj))
+	objectTemplatePrunedTotal.DeleteLabelValues(obj.Namespace, obj.Name)
 
 	// Remove our finalizer from the list and update it
 	controllerutil.RemoveFinalizer(obj, templatesv1alpha1.ObjectTemplateFinalizer)
@@ -496,41 +2662,204 @@ func (r *ObjectTemplateReconciler) finalize(ctx context.Context, obj *templatesv
 	return ctrl.Result{}, nil
 }
 
-func (r *ObjectTemplateReconciler) doFinalize(ctx context.Context, obj *templatesv1alpha1.ObjectTemplate) {
+func (r *ObjectTemplateReconciler) doFinalize(ctx context.Context, obj *templatesv1alpha1.ObjectTemplate) error {
 	log := ctrl.LoggerFrom(ctx)
 
 	if !obj.Spec.Prune || obj.Spec.Suspend {
-		return
+		return nil
 	}
 
 	objClient, err := r.getClientForObjects(obj.Spec.ServiceAccountName, obj.GetNamespace())
 	if err != nil {
 		log.Error(err, "Failed to create objClient for deletion")
-		return
+		return err
+	}
+	objClient, _, err = r.getTargetClient(ctx, objClient, obj)
+	if err != nil {
+		log.Error(err, "Failed to create target client for deletion")
+		return err
 	}
 
-	var wg sync.WaitGroup
+	var refs []templatesv1alpha1.ObjectRef
 	for _, ar := range obj.Status.AppliedResources {
-		ar := ar
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			gvk, err := ar.Ref.GroupVersionKind()
-			if err != nil {
-				return
-			}
+		if isManagedByTemplate(ar) {
+			refs = append(refs, ar.Ref)
+		}
+	}
+	liveObjects, err := r.loadPruneObjects(ctx, objClient, refs)
+	if err != nil {
+		return err
+	}
+
+	var toDelete []*metav1.PartialObjectMetadata
+	for _, ref := range refs {
+		m, ok := liveObjects[ref]
+		if !ok {
+			continue
+		}
+		if isPruneDisabled(m) {
+			log.Info("Not deleting applied object with disabled pruning", "ref", ref)
+			continue
+		}
+		toDelete = append(toDelete, m)
+	}
+
+	done, err := r.deleteInPruneOrder(ctx, objClient, toDelete, func(m *metav1.PartialObjectMetadata, err error) {
+		ref := templatesv1alpha1.ObjectRefFromObject(m)
+		if err != nil {
+			log.Error(err, "Failed to delete applied object", "ref", ref)
+			r.recordEvent(obj, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete %s: %s", eventObjectString(ref), err.Error())
+			return
+		}
+		log.Info("Deleted applied object", "ref", ref)
+	})
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("waiting for deleted objects to disappear before deleting objects with a higher prune order")
+	}
+	return nil
+}
 
-			log.Info("Deleting applied object", "ref", ar.Ref)
+// buildMatrixObjectIndexValues returns the forMatrixObjectKey index values of all objects referenced by the matrix.
+// Refs with an explicit namespace are indexed with that namespace, so that changes to objects in other namespaces
+// enqueue the referencing template. Templated refs are left out, as these only match after rendering
+func (r *ObjectTemplateReconciler) buildMatrixObjectIndexValues(rt *templatesv1alpha1.ObjectTemplate) []string {
+	var ret []string
+	for _, me := range rt.Spec.Matrix {
+		if me.Object != nil && me.Object.IsTemplated() {
+			continue
+		}
+		ref := r.buildMatrixEntryRef(me)
+		if ref != nil {
+			ret = append(ret, BuildRefIndexValue(*ref, rt.GetNamespace()))
+		}
+	}
+	return ret
+}
 
-			var o unstructured.Unstructured
-			o.SetGroupVersionKind(gvk)
-			o.SetName(ar.Ref.Name)
-			o.SetNamespace(ar.Ref.Namespace)
-			err = objClient.Delete(ctx, &o)
-			if err != nil && !errors.IsNotFound(err) {
-				log.Error(err, "Failed to delete applied object", "ref", ar.Ref)
+// buildMatrixObjectListIndexValues returns the forMatrixObjectListKey index values of the matrix. Besides objectList
+// and namespaces inputs, this includes templated object refs, which are indexed by kind and namespace so that changes
+// to any object that might be referenced after rendering enqueue the template. Refs with a templated namespace can't
+// be indexed and are only reloaded on the next interval
+func (r *ObjectTemplateReconciler) buildMatrixObjectListIndexValues(rt *templatesv1alpha1.ObjectTemplate) []string {
+	var ret []string
+	for _, me := range rt.Spec.Matrix {
+		if me.ObjectList != nil {
+			ret = append(ret, BuildKindNamespaceIndexValue(me.ObjectList.Kind, r.buildObjectListNamespace(rt, me.ObjectList)))
+		}
+		if me.Namespaces != nil {
+			ret = append(ret, BuildKindNamespaceIndexValue(namespaceGVK.Kind, ""))
+		}
+		if me.Object != nil && me.Object.IsTemplated() && !templatesv1alpha1.IsTemplatedString(me.Object.Ref.Namespace) {
+			ns := rt.GetNamespace()
+			if me.Object.Ref.Namespace != "" {
+				ns = me.Object.Ref.Namespace
 			}
-		}()
+			ret = append(ret, BuildKindNamespaceIndexValue(me.Object.Ref.Kind, ns))
+		}
+	}
+	return ret
+}
+
+// buildMatrixEntryRef returns a reference to the object that is loaded by the given matrix entry. It returns nil if the
+// matrix entry does not load any object from the cluster.
+func (r *ObjectTemplateReconciler) buildMatrixEntryRef(me *templatesv1alpha1.MatrixEntry) *templatesv1alpha1.ObjectRef {
+	if me.Object != nil {
+		return &me.Object.Ref
+	} else if me.ConfigMap != nil {
+		return &templatesv1alpha1.ObjectRef{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  me.ConfigMap.Ref.Namespace,
+			Name:       me.ConfigMap.Ref.Name,
+		}
+	} else if me.Secret != nil {
+		return &templatesv1alpha1.ObjectRef{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Namespace:  me.Secret.Ref.Namespace,
+			Name:       me.Secret.Ref.Name,
+		}
+	} else if me.ObjectTemplate != nil {
+		return &templatesv1alpha1.ObjectRef{
+			APIVersion: templatesv1alpha1.GroupVersion.String(),
+			Kind:       "ObjectTemplate",
+			Namespace:  me.ObjectTemplate.Ref.Namespace,
+			Name:       me.ObjectTemplate.Ref.Name,
+		}
+	} else {
+		return nil
+	}
+}
+
+// buildObjectListExcludeFunc returns a function that decides whether a listed object is excluded from the matrix, or
+// nil if nothing is excluded
+func buildObjectListExcludeFunc(j2 *jinja2.Jinja2, limits *RenderLimits, me *templatesv1alpha1.MatrixEntryObjectList, renderOpts []jinja2.Jinja2Opt) func(o *unstructured.Unstructured) (bool, error) {
+	if !me.ExcludeDeleting && me.ExcludeWhen == "" {
+		return nil
+	}
+	return func(o *unstructured.Unstructured) (bool, error) {
+		if me.ExcludeDeleting && o.GetDeletionTimestamp() != nil {
+			return true, nil
+		}
+		if me.ExcludeWhen == "" {
+			return false, nil
+		}
+		excluded, err := limits.evalCondition(j2, me.ExcludeWhen, map[string]any{"object": o.Object}, renderOpts...)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate excludeWhen for %s/%s: %w", o.GetNamespace(), o.GetName(), err)
+		}
+		return excluded, nil
+	}
+}
+
+func (r *ObjectTemplateReconciler) buildObjectListNamespace(rt *templatesv1alpha1.ObjectTemplate, me *templatesv1alpha1.MatrixEntryObjectList) string {
+	if me.Namespace != "" {
+		return me.Namespace
+	}
+	return rt.GetNamespace()
+}
+
+// buildConfigMapRefs returns references to all ConfigMaps used by the ObjectTemplate outside of the matrix
+func (r *ObjectTemplateReconciler) buildConfigMapRefs(rt *templatesv1alpha1.ObjectTemplate) []templatesv1alpha1.ObjectRef {
+	var ret []templatesv1alpha1.ObjectRef
+	if rt.Spec.FiltersConfigMapRef != nil {
+		ret = append(ret, templatesv1alpha1.ObjectRef{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  rt.GetNamespace(),
+			Name:       rt.Spec.FiltersConfigMapRef.Name,
+		})
+	}
+	if rt.Spec.IncludesConfigMapRef != nil {
+		ret = append(ret, templatesv1alpha1.ObjectRef{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  rt.GetNamespace(),
+			Name:       rt.Spec.IncludesConfigMapRef.Name,
+		})
+	}
+	for _, vs := range rt.Spec.Vars {
+		if vs.ConfigMap != nil {
+			ret = append(ret, templatesv1alpha1.ObjectRef{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Namespace:  rt.GetNamespace(),
+				Name:       vs.ConfigMap.Name,
+			})
+		}
+	}
+	for _, t := range rt.Spec.Templates {
+		if t.ConfigMap != nil {
+			ret = append(ret, templatesv1alpha1.ObjectRef{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Namespace:  rt.GetNamespace(),
+				Name:       t.ConfigMap.Name,
+			})
+		}
 	}
-	wg.Wait()
+	return ret
 }
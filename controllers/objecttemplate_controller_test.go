@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsRetriableApplyError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	applyConflict := errors.NewConflict(gr, "cm", fmt.Errorf("conflict with other manager"))
+	applyConflict.ErrStatus.Details.Causes = []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Message: `conflict with "other"`,
+		Field:   ".data.a",
+	}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "resourceVersion conflict", err: errors.NewConflict(gr, "cm", fmt.Errorf("object was modified")), want: true},
+		{name: "field manager conflict", err: applyConflict, want: false},
+		{name: "too many requests", err: errors.NewTooManyRequests("slow down", 1), want: true},
+		{name: "internal error", err: errors.NewInternalError(fmt.Errorf("boom")), want: true},
+		{name: "invalid", err: errors.NewBadRequest("invalid"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetriableApplyError(tt.err); got != tt.want {
+				t.Errorf("isRetriableApplyError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
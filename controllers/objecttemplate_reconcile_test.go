@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kluctl/go-jinja2"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeRenderer renders one ConfigMap per matrix entry, named after the `i` range matrix entry
+type fakeRenderer struct{}
+
+func (fakeRenderer) render(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, vars map[string]any) ([]*unstructured.Unstructured, map[*unstructured.Unstructured]string, map[*unstructured.Unstructured]string, error) {
+	matrix := vars["matrix"].(map[string]any)
+	o := buildTestObject("ConfigMap", rt.Namespace, fmt.Sprintf("cm-%v", matrix["i"]))
+	o.Object["data"] = map[string]any{"index": fmt.Sprintf("%v", vars["matrixIndex"])}
+	return []*unstructured.Unstructured{o}, nil, nil, nil
+}
+
+// fakeClientApplier creates or updates objects in the fake client, which does not support server-side apply
+type fakeClientApplier struct{}
+
+func (fakeClientApplier) Apply(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, applyMode string, opts ...client.PatchOption) error {
+	err := objClient.Create(ctx, obj)
+	if !errors.IsAlreadyExists(err) {
+		return err
+	}
+	var live unstructured.Unstructured
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	err = objClient.Get(ctx, client.ObjectKeyFromObject(obj), &live)
+	if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(live.GetResourceVersion())
+	return objClient.Update(ctx, obj)
+}
+
+func newFakeClient(objs ...client.Object) client.WithWatch {
+	return fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)).
+		WithObjects(objs...).
+		Build()
+}
+
+// newFakeReconciler returns a reconciler that neither needs a manager, a cluster nor a Jinja2 process
+func newFakeReconciler(c client.Client) *ObjectTemplateReconciler {
+	return &ObjectTemplateReconciler{
+		BaseTemplateReconciler: BaseTemplateReconciler{
+			Client:       c,
+			Scheme:       scheme.Scheme,
+			FieldManager: "template-controller",
+			newClientForObjects: func(serviceAccountName string, objNamespace string) (client.Client, error) {
+				return c, nil
+			},
+		},
+		Applier:     fakeClientApplier{},
+		Synchronous: true,
+		newRenderer: func(targetClient client.Client, j2 *jinja2.Jinja2, j2Opts []jinja2.Jinja2Opt, templateConfigMaps map[string]*corev1.ConfigMap) objectRenderer {
+			return fakeRenderer{}
+		},
+		newClusterInfo: func(restConfig *rest.Config) (map[string]any, error) {
+			return map[string]any{"version": map[string]any{}, "apiVersions": []any{"v1"}}, nil
+		},
+	}
+}
+
+func buildRangeTemplate(stop int64) *templatesv1alpha1.ObjectTemplate {
+	rt := &templatesv1alpha1.ObjectTemplate{}
+	rt.Namespace = "ns"
+	rt.Name = "rt"
+	rt.UID = "uid"
+	rt.Generation = 1
+	rt.Spec.Prune = true
+	rt.Spec.Matrix = []*templatesv1alpha1.MatrixEntry{
+		{Name: "i", Range: &templatesv1alpha1.MatrixEntryRange{Stop: stop, Step: 1}},
+	}
+	rt.Spec.Templates = []templatesv1alpha1.Template{{Object: &unstructured.Unstructured{}}}
+	return rt
+}
+
+func listConfigMapNames(t *testing.T, c client.Client) []string {
+	var l corev1.ConfigMapList
+	if err := c.List(context.Background(), &l, client.InNamespace("ns")); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, x := range l.Items {
+		names = append(names, x.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestForceReconcileAppliesAndPrunes(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+	ctx := context.Background()
+
+	rt := buildRangeTemplate(3)
+	if err := r.forceReconcile(ctx, rt); err != nil {
+		t.Fatal(err)
+	}
+	if names := listConfigMapNames(t, c); fmt.Sprint(names) != "[cm-0 cm-1 cm-2]" {
+		t.Errorf("unexpected objects after first reconcile: %v", names)
+	}
+	if len(rt.Status.AppliedResources) != 3 || rt.Status.MatrixCount != 3 {
+		t.Errorf("unexpected status: %d applied resources, matrixCount %d", len(rt.Status.AppliedResources), rt.Status.MatrixCount)
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "cm-2"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["index"] != "2" {
+		t.Errorf("unexpected data: %v", cm.Data)
+	}
+
+	rt.Spec.Matrix[0].Range.Stop = 1
+	rt.Generation++
+	if err := r.forceReconcile(ctx, rt); err != nil {
+		t.Fatal(err)
+	}
+	if names := listConfigMapNames(t, c); fmt.Sprint(names) != "[cm-0]" {
+		t.Errorf("unexpected objects after prune: %v", names)
+	}
+	if len(rt.Status.AppliedResources) != 1 {
+		t.Errorf("unexpected applied resources after prune: %v", rt.Status.AppliedResources)
+	}
+}
+
+// reconcileUnchanged runs a reconciliation with skipUnchangedSources enabled and sets the Ready condition like
+// Reconcile would do, so that sourcesUnchanged can be checked afterwards
+func reconcileUnchanged(t *testing.T, r *ObjectTemplateReconciler, rt *templatesv1alpha1.ObjectTemplate) {
+	rt.Spec.SkipUnchangedSources = true
+	if err := r.forceReconcile(context.Background(), rt); err != nil {
+		t.Fatal(err)
+	}
+	apimeta.SetStatusCondition(&rt.Status.Conditions, metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: rt.GetGeneration(),
+		Reason:             "Succeeded",
+	})
+}
+
+func checkSourcesUnchanged(t *testing.T, r *ObjectTemplateReconciler, rt *templatesv1alpha1.ObjectTemplate, expected bool) {
+	t.Helper()
+	unchanged, err := r.sourcesUnchanged(context.Background(), rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged != expected {
+		t.Errorf("expected sourcesUnchanged to return %v", expected)
+	}
+}
+
+func TestSkipUnchangedSources(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+
+	rt := buildRangeTemplate(2)
+	reconcileUnchanged(t, r, rt)
+	if rt.Status.ObservedSources == nil {
+		t.Fatal("expected observedSources to be recorded")
+	}
+	checkSourcesUnchanged(t, r, rt, true)
+
+	rt.Generation++
+	checkSourcesUnchanged(t, r, rt, false)
+}
+
+func TestSkipUnchangedSourcesPendingGracePeriod(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+
+	rt := buildRangeTemplate(2)
+	rt.Spec.PruneGracePeriod = &metav1.Duration{Duration: time.Hour}
+	reconcileUnchanged(t, r, rt)
+
+	rt.Spec.Matrix[0].Range.Stop = 1
+	rt.Generation++
+	reconcileUnchanged(t, r, rt)
+	if names := listConfigMapNames(t, c); fmt.Sprint(names) != "[cm-0 cm-1]" {
+		t.Errorf("expected pruning to be delayed, got %v", names)
+	}
+	if rt.Status.ObservedSources != nil {
+		t.Errorf("expected observedSources to not be recorded while pruning is delayed")
+	}
+	checkSourcesUnchanged(t, r, rt, false)
+
+	// even with recorded sources, prune candidates prevent skipping
+	observedSources, err := r.loadObservedSources(context.Background(), c, rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.Status.ObservedSources = observedSources
+	checkSourcesUnchanged(t, r, rt, false)
+}
+
+func TestSkipUnchangedSourcesPendingPruneOrder(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+	ctx := context.Background()
+
+	rt := buildRangeTemplate(3)
+	reconcileUnchanged(t, r, rt)
+
+	// cm-1 is deleted first but can't disappear due to its finalizer, so cm-2 must wait
+	for name, order := range map[string]string{"cm-1": "1", "cm-2": "2"} {
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: name}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		cm.Annotations = map[string]string{templatesv1alpha1.PruneOrderAnnotation: order}
+		if name == "cm-1" {
+			cm.Finalizers = []string{"test/finalizer"}
+		}
+		if err := c.Update(ctx, &cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt.Spec.Matrix[0].Range.Stop = 1
+	rt.Generation++
+	reconcileUnchanged(t, r, rt)
+	if names := listConfigMapNames(t, c); fmt.Sprint(names) != "[cm-0 cm-1 cm-2]" {
+		t.Errorf("expected cm-2 to wait for cm-1, got %v", names)
+	}
+	if rt.Status.ObservedSources != nil {
+		t.Errorf("expected observedSources to not be recorded while prunes are pending")
+	}
+	checkSourcesUnchanged(t, r, rt, false)
+}
+
+func TestSkipUnchangedSourcesVanishedObject(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+	ctx := context.Background()
+
+	rt := buildRangeTemplate(2)
+	reconcileUnchanged(t, r, rt)
+	checkSourcesUnchanged(t, r, rt, true)
+
+	cm := &corev1.ConfigMap{}
+	cm.Namespace = "ns"
+	cm.Name = "cm-1"
+	if err := c.Delete(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	checkSourcesUnchanged(t, r, rt, false)
+
+	reconcileUnchanged(t, r, rt)
+	if names := listConfigMapNames(t, c); fmt.Sprint(names) != "[cm-0 cm-1]" {
+		t.Errorf("expected cm-1 to be re-created, got %v", names)
+	}
+	checkSourcesUnchanged(t, r, rt, true)
+}
+
+// failingApplier fails to apply the objects with the given names and applies all other objects with the
+// fakeClientApplier
+type failingApplier struct {
+	names map[string]bool
+}
+
+func (a *failingApplier) Apply(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, applyMode string, opts ...client.PatchOption) error {
+	if a.names[obj.GetName()] {
+		return fmt.Errorf("simulated apply failure")
+	}
+	return fakeClientApplier{}.Apply(ctx, objClient, rt, obj, applyMode, opts...)
+}
+
+func TestPruneAfterFailedApply(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+	applier := &failingApplier{}
+	r.Applier = applier
+	ctx := context.Background()
+
+	rt := buildRangeTemplate(2)
+	if err := r.forceReconcile(ctx, rt); err != nil {
+		t.Fatal(err)
+	}
+
+	// modifying cm-1 out of band prevents the controller from skipping the unchanged object
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "cm-1"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Labels = map[string]string{"modified": "true"}
+	if err := c.Update(ctx, &cm); err != nil {
+		t.Fatal(err)
+	}
+	applier.names = map[string]bool{"cm-1": true}
+	rt.Generation++
+	if err := r.forceReconcile(ctx, rt); err == nil {
+		t.Fatal("expected apply to fail")
+	}
+
+	// cm-1 was created by the first reconciliation, so it must be pruned even though its last apply failed
+	applier.names = nil
+	rt.Spec.Matrix[0].Range.Stop = 1
+	rt.Generation++
+	if err := r.forceReconcile(ctx, rt); err != nil {
+		t.Fatal(err)
+	}
+	if names := listConfigMapNames(t, c); fmt.Sprint(names) != "[cm-0]" {
+		t.Errorf("expected cm-1 to be pruned, got %v", names)
+	}
+	if len(rt.Status.OrphanedResources) != 0 {
+		t.Errorf("unexpected orphaned resources: %v", rt.Status.OrphanedResources)
+	}
+}
+
+func TestMaxResourcesCheckedBeforeRendering(t *testing.T) {
+	c := newFakeClient()
+	r := newFakeReconciler(c)
+	r.MaxResources = 2
+	rendered := 0
+	r.newRenderer = func(targetClient client.Client, j2 *jinja2.Jinja2, j2Opts []jinja2.Jinja2Opt, templateConfigMaps map[string]*corev1.ConfigMap) objectRenderer {
+		rendered++
+		return fakeRenderer{}
+	}
+
+	rt := buildRangeTemplate(3)
+	err := r.forceReconcile(context.Background(), rt)
+	if !isMaxResourcesExceededError(err) {
+		t.Fatalf("expected max resources error, got %v", err)
+	}
+	if rendered != 0 {
+		t.Errorf("expected nothing to be rendered")
+	}
+
+	// templates with `when` might not render anything, so they are only counted after rendering
+	rt.Spec.Templates[0].When = "true"
+	rt.Generation++
+	err = r.forceReconcile(context.Background(), rt)
+	if !isMaxResourcesExceededError(err) {
+		t.Fatalf("expected max resources error, got %v", err)
+	}
+	if rendered == 0 {
+		t.Errorf("expected the matrix to be rendered")
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// canTrackSources returns true if skipping unchanged sources is enabled and all inputs of the ObjectTemplate can be
+// tracked by the resourceVersions of single objects
+func canTrackSources(rt *templatesv1alpha1.ObjectTemplate) bool {
+	if !rt.Spec.SkipUnchangedSources || rt.Spec.DryRun || rt.Spec.DetectDrift {
+		return false
+	}
+	for _, me := range rt.Spec.Matrix {
+		if me.ObjectList != nil || me.Namespaces != nil || me.HTTP != nil || me.Git != nil {
+			return false
+		}
+		if me.Object != nil && me.Object.IsTemplated() {
+			return false
+		}
+	}
+	for _, t := range rt.Spec.Templates {
+		if t.ExistingRef != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSourceRefs returns the refs of all objects the ObjectTemplate reads from, with the namespace defaulted
+func (r *ObjectTemplateReconciler) buildSourceRefs(rt *templatesv1alpha1.ObjectTemplate) []templatesv1alpha1.ObjectRef {
+	var ret []templatesv1alpha1.ObjectRef
+	for _, me := range rt.Spec.Matrix {
+		ref := r.buildMatrixEntryRef(me)
+		if ref != nil {
+			ret = append(ret, *ref)
+		}
+	}
+	ret = append(ret, r.buildConfigMapRefs(rt)...)
+	for _, vs := range rt.Spec.Vars {
+		if vs.Secret != nil {
+			ret = append(ret, templatesv1alpha1.ObjectRef{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Name:       vs.Secret.Name,
+			})
+		}
+	}
+	if rt.Spec.KubeConfig != nil {
+		ret = append(ret, templatesv1alpha1.ObjectRef{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Name:       rt.Spec.KubeConfig.SecretRef.Name,
+		})
+	}
+	for i := range ret {
+		if ret[i].Namespace == "" {
+			ret[i].Namespace = rt.GetNamespace()
+		}
+	}
+	return ret
+}
+
+// loadObservedSources loads the current resourceVersions of all sources. Only metadata is requested, so that no
+// secret values are loaded. Missing objects are recorded with an empty resourceVersion, as optional inputs might not
+// exist yet
+func (r *ObjectTemplateReconciler) loadObservedSources(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) (*templatesv1alpha1.ObservedSources, error) {
+	metadataHash, err := r.buildMetadataHash(rt)
+	if err != nil {
+		return nil, err
+	}
+	ret := &templatesv1alpha1.ObservedSources{
+		Generation:   rt.GetGeneration(),
+		MetadataHash: metadataHash,
+	}
+	for _, ref := range r.buildSourceRefs(rt) {
+		gvk, err := ref.GroupVersionKind()
+		if err != nil {
+			return nil, err
+		}
+		var o metav1.PartialObjectMetadata
+		o.SetGroupVersionKind(gvk)
+		err = objClient.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &o)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, wrapKindNotInstalledError(gvk, err)
+		}
+		ret.Objects = append(ret.Objects, templatesv1alpha1.ObservedSourceObject{
+			Ref:             ref,
+			ResourceVersion: o.GetResourceVersion(),
+		})
+	}
+	return ret, nil
+}
+
+// buildMetadataHash hashes the parts of the objectTemplate and controller variables that are not covered by the
+// generation
+func (r *ObjectTemplateReconciler) buildMetadataHash(rt *templatesv1alpha1.ObjectTemplate) (string, error) {
+	b, err := json.Marshal(map[string]any{
+		"labels":            rt.GetLabels(),
+		"annotations":       rt.GetAnnotations(),
+		"controllerVersion": r.ControllerVersion,
+	})
+	if err != nil {
+		return "", err
+	}
+	return Sha256Bytes(b), nil
+}
+
+// sourcesUnchanged returns true if the last reconciliation succeeded and neither the ObjectTemplate nor any of its
+// sources changed since then, so that the reconciliation can be skipped. It also returns false if prunes are pending
+// or applied objects were deleted in the meantime, as only a reconciliation prunes or re-creates these
+func (r *ObjectTemplateReconciler) sourcesUnchanged(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate) (bool, error) {
+	last := rt.Status.ObservedSources
+	if last == nil || !canTrackSources(rt) || last.Generation != rt.GetGeneration() {
+		return false, nil
+	}
+	if reconcileRequest := getReconcileRequest(rt); reconcileRequest != "" && reconcileRequest != rt.Status.LastHandledReconcileAt {
+		return false, nil
+	}
+	c := apimeta.FindStatusCondition(rt.Status.Conditions, "Ready")
+	if c == nil || c.Status != metav1.ConditionTrue || c.ObservedGeneration != rt.GetGeneration() {
+		return false, nil
+	}
+	for _, ari := range rt.Status.AppliedResources {
+		if !ari.Success || ari.PruneCandidateSince != nil {
+			return false, nil
+		}
+	}
+
+	objClient, err := r.getClientForObjects(rt.Spec.ServiceAccountName, rt.GetNamespace())
+	if err != nil {
+		return false, err
+	}
+	current, err := r.loadObservedSources(ctx, objClient, rt)
+	if err != nil {
+		return false, err
+	}
+	if !equality.Semantic.DeepEqual(last, current) {
+		return false, nil
+	}
+
+	targetClient, _, err := r.getTargetClient(ctx, objClient, rt)
+	if err != nil {
+		return false, err
+	}
+	return r.appliedResourcesExist(ctx, targetClient, rt)
+}
+
+// appliedResourcesExist returns true if all objects in status.appliedResources still exist. Only metadata is loaded,
+// so changes to the applied objects other than deletion are not detected
+func (r *ObjectTemplateReconciler) appliedResourcesExist(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate) (bool, error) {
+	refs := make([]templatesv1alpha1.ObjectRef, 0, len(rt.Status.AppliedResources))
+	for _, ari := range rt.Status.AppliedResources {
+		refs = append(refs, ari.Ref)
+	}
+	liveObjects, err := r.loadPruneObjects(ctx, objClient, refs)
+	if err != nil {
+		return false, err
+	}
+	return len(liveObjects) == len(refs), nil
+}
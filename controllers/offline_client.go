@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// errOfflineWrite is returned by all write operations of the offlineClient
+var errOfflineWrite = fmt.Errorf("writing is not supported when rendering offline")
+
+// offlineRootScopedKinds contains the cluster-scoped kinds of the built-in APIs. All other kinds of the scheme are
+// assumed to be namespaced
+var offlineRootScopedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}:        true,
+	{Kind: "Node"}:             true,
+	{Kind: "PersistentVolume"}: true,
+	{Kind: "ComponentStatus"}:  true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      true,
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                        true,
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                true,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      true,
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       true,
+}
+
+// newOfflineRESTMapper returns a RESTMapper for all kinds of the scheme and the kinds defined by the given CRDs
+func newOfflineRESTMapper(scheme *runtime.Scheme, crds []*unstructured.Unstructured) apimeta.RESTMapper {
+	m := apimeta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups())
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version == "" || gvk.Version == runtime.APIVersionInternal || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		scope := apimeta.RESTScopeNamespace
+		if offlineRootScopedKinds[gvk.GroupKind()] {
+			scope = apimeta.RESTScopeRoot
+		}
+		m.Add(gvk, scope)
+	}
+	return &offlineRESTMapper{
+		RESTMapper:  m,
+		crdMappings: buildRenderedCRDMappings(crds),
+	}
+}
+
+// offlineClient is a read-only client that serves the given objects instead of the objects on a cluster
+type offlineClient struct {
+	scheme  *runtime.Scheme
+	mapper  apimeta.RESTMapper
+	objects []*unstructured.Unstructured
+}
+
+func newOfflineClient(scheme *runtime.Scheme, mapper apimeta.RESTMapper, objects []*unstructured.Unstructured) *offlineClient {
+	c := &offlineClient{
+		scheme: scheme,
+		mapper: mapper,
+	}
+	for _, x := range objects {
+		c.objects = append(c.objects, x.DeepCopy())
+	}
+	return c
+}
+
+func (c *offlineClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return err
+	}
+	for _, x := range c.objects {
+		if x.GroupVersionKind().GroupKind() != gvk.GroupKind() || x.GetNamespace() != key.Namespace || x.GetName() != key.Name {
+			continue
+		}
+		return c.convert(x.Object, obj)
+	}
+	return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, key.Name)
+}
+
+func (c *offlineClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	gvk, err := apiutil.GVKForObject(list, c.scheme)
+	if err != nil {
+		return err
+	}
+	gk := schema.GroupKind{Group: gvk.Group, Kind: strings.TrimSuffix(gvk.Kind, "List")}
+
+	var o client.ListOptions
+	o.ApplyOptions(opts)
+
+	var items []unstructured.Unstructured
+	for _, x := range c.objects {
+		if x.GroupVersionKind().GroupKind() != gk {
+			continue
+		}
+		if o.Namespace != "" && x.GetNamespace() != o.Namespace {
+			continue
+		}
+		if o.LabelSelector != nil && !o.LabelSelector.Matches(labels.Set(x.GetLabels())) {
+			continue
+		}
+		items = append(items, *x.DeepCopy())
+	}
+
+	if ul, ok := list.(*unstructured.UnstructuredList); ok {
+		ul.Items = items
+		return nil
+	}
+	itemsAny := make([]any, 0, len(items))
+	for _, x := range items {
+		itemsAny = append(itemsAny, x.Object)
+	}
+	return c.convert(map[string]any{
+		"apiVersion": gvk.GroupVersion().String(),
+		"kind":       gvk.Kind,
+		"items":      itemsAny,
+	}, list)
+}
+
+// convert fills obj, which can be unstructured, typed or metadata only, from the unstructured object u
+func (c *offlineClient) convert(u map[string]any, obj runtime.Object) error {
+	switch x := obj.(type) {
+	case *unstructured.Unstructured:
+		x.Object = runtime.DeepCopyJSON(u)
+		return nil
+	case *metav1.PartialObjectMetadata:
+		gvk := x.GroupVersionKind()
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, x)
+		x.SetGroupVersionKind(gvk)
+		return err
+	default:
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(u, obj)
+	}
+}
+
+func (c *offlineClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return errOfflineWrite
+}
+
+func (c *offlineClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return errOfflineWrite
+}
+
+func (c *offlineClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return errOfflineWrite
+}
+
+func (c *offlineClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return errOfflineWrite
+}
+
+func (c *offlineClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return errOfflineWrite
+}
+
+func (c *offlineClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *offlineClient) SubResource(subResource string) client.SubResourceClient {
+	return offlineSubResourceClient{}
+}
+
+func (c *offlineClient) Scheme() *runtime.Scheme {
+	return c.scheme
+}
+
+func (c *offlineClient) RESTMapper() apimeta.RESTMapper {
+	return c.mapper
+}
+
+func (c *offlineClient) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return apiutil.GVKForObject(obj, c.scheme)
+}
+
+func (c *offlineClient) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	return apiutil.IsObjectNamespaced(obj, c.scheme, c.mapper)
+}
+
+// offlineSubResourceClient rejects all operations on subresources, as these don't exist offline
+type offlineSubResourceClient struct{}
+
+func (offlineSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return fmt.Errorf("subresources are not supported when rendering offline")
+}
+
+func (offlineSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return errOfflineWrite
+}
+
+func (offlineSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return errOfflineWrite
+}
+
+func (offlineSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return errOfflineWrite
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"sync"
+)
+
+// RenderOffline renders the ObjectTemplate the same way as a reconciliation would, but without access to a cluster.
+// Matrix inputs, vars, filters and template ConfigMaps are looked up in inputs instead. The returned objects are the
+// objects that would be applied, in the order they would be applied. Everything that only makes sense with a cluster
+// (waiting, pruning, drift detection, schema validation, the inventory and the debug output) is disabled. r is only used
+// as configuration for a separate offline reconciler and is not modified
+func (r *ObjectTemplateReconciler) RenderOffline(ctx context.Context, rt *templatesv1alpha1.ObjectTemplate, inputs []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	rt = rt.DeepCopy()
+	rt.Status = templatesv1alpha1.ObjectTemplateStatus{}
+	rt.Spec.Suspend = false
+	rt.Spec.DryRun = false
+	rt.Spec.Prune = false
+	rt.Spec.DetectDrift = false
+	rt.Spec.ValidateSchema = false
+	rt.Spec.Wait = nil
+	rt.Spec.Inventory = nil
+	rt.Spec.Debug = nil
+	rt.Spec.KubeConfig = nil
+	rt.Spec.SkipUnchangedSources = false
+
+	c := newOfflineClient(r.Scheme, newOfflineRESTMapper(r.Scheme, inputs), inputs)
+	applier := &recordingApplier{}
+	// there is no cluster to discover, so only the API versions known to the scheme and the input CRDs are exposed
+	clusterInfo := buildOfflineClusterInfo(r.Scheme, inputs)
+	offline := &ObjectTemplateReconciler{
+		BaseTemplateReconciler: BaseTemplateReconciler{
+			Client:            c,
+			Scheme:            r.Scheme,
+			FieldManager:      r.FieldManager,
+			ControllerName:    r.ControllerName,
+			ControllerVersion: r.ControllerVersion,
+			AllowedNamespaces: r.AllowedNamespaces,
+			newClientForObjects: func(serviceAccountName string, objNamespace string) (client.Client, error) {
+				return c, nil
+			},
+		},
+		EnableCustomJinja2Filters: r.EnableCustomJinja2Filters,
+		KindPolicy:                r.KindPolicy,
+		TmpBaseDir:                r.TmpBaseDir,
+		RenderLimits:              r.RenderLimits,
+		MaxResources:              r.MaxResources,
+		Applier:                   applier,
+		Synchronous:               true,
+		newClusterInfo: func(restConfig *rest.Config) (map[string]any, error) {
+			return clusterInfo, nil
+		},
+		j2Pool: newJinja2Pool(1),
+	}
+	defer offline.j2Pool.Close()
+
+	err := offline.doReconcile(ctx, rt)
+	if err != nil {
+		return nil, err
+	}
+	return applier.objects, nil
+}
+
+// buildOfflineClusterInfo builds the `cluster` variable from the API versions of the scheme and of the input CRDs. The
+// server version is unknown and left empty
+func buildOfflineClusterInfo(scheme *runtime.Scheme, inputs []*unstructured.Unstructured) map[string]any {
+	apiVersions := map[string]bool{}
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Version != "" && gvk.Version != runtime.APIVersionInternal {
+			apiVersions[gvk.GroupVersion().String()] = true
+		}
+	}
+	for _, x := range inputs {
+		if !isCRD(x) {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(x.Object, "spec", "group")
+		versions, _, _ := unstructured.NestedSlice(x.Object, "spec", "versions")
+		for _, v := range versions {
+			m, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			if name, ok := m["name"].(string); ok {
+				apiVersions[schema.GroupVersion{Group: group, Version: name}.String()] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(apiVersions))
+	for v := range apiVersions {
+		sorted = append(sorted, v)
+	}
+	sort.Strings(sorted)
+	apiVersionsAny := make([]any, 0, len(sorted))
+	for _, v := range sorted {
+		apiVersionsAny = append(apiVersionsAny, v)
+	}
+
+	return map[string]any{
+		"version": map[string]any{
+			"major":      "",
+			"minor":      "",
+			"gitVersion": "",
+			"platform":   "",
+		},
+		"apiVersions": apiVersionsAny,
+	}
+}
+
+// offlineRESTMapper knows about all kinds of the scheme and the kinds defined by input CRDs. All other kinds are
+// assumed to be namespaced, as there is no cluster to ask
+type offlineRESTMapper struct {
+	apimeta.RESTMapper
+	crdMappings map[schema.GroupKind]*apimeta.RESTMapping
+}
+
+func (m *offlineRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*apimeta.RESTMapping, error) {
+	rm, err := m.RESTMapper.RESTMapping(gk, versions...)
+	if err == nil || !apimeta.IsNoMatchError(err) {
+		return rm, err
+	}
+
+	version := ""
+	if len(versions) != 0 {
+		version = versions[0]
+	}
+	gvk := gk.WithVersion(version)
+	if crdMapping, ok := m.crdMappings[gk]; ok {
+		rm := *crdMapping
+		rm.GroupVersionKind = gvk
+		rm.Resource.Version = version
+		return &rm, nil
+	}
+	plural, _ := apimeta.UnsafeGuessKindToResource(gvk)
+	return &apimeta.RESTMapping{
+		Resource:         plural,
+		GroupVersionKind: gvk,
+		Scope:            apimeta.RESTScopeNamespace,
+	}, nil
+}
+
+// recordingApplier collects the applied objects instead of sending them to a cluster
+type recordingApplier struct {
+	mutex   sync.Mutex
+	objects []*unstructured.Unstructured
+}
+
+func (a *recordingApplier) Apply(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, obj *unstructured.Unstructured, applyMode string, opts ...client.PatchOption) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.objects = append(a.objects, obj.DeepCopy())
+	return nil
+}
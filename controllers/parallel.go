@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"sync"
+)
+
+// parallelRunner runs functions concurrently and allows to wait for all of them to finish. When a limit is set, at
+// most that many functions run at the same time. In synchronous mode, functions are run one after another in the
+// calling goroutine instead, which makes the order of operations deterministic
+type parallelRunner struct {
+	wg          sync.WaitGroup
+	sem         chan struct{}
+	synchronous bool
+}
+
+func newParallelRunner(limit int, synchronous bool) *parallelRunner {
+	p := &parallelRunner{
+		synchronous: synchronous,
+	}
+	if limit > 0 {
+		p.sem = make(chan struct{}, limit)
+	}
+	return p
+}
+
+// run executes fn. It blocks until fn finished in synchronous mode and until a free slot is available otherwise
+func (p *parallelRunner) run(fn func()) {
+	if p.synchronous {
+		fn()
+		return
+	}
+
+	p.wg.Add(1)
+	if p.sem != nil {
+		p.sem <- struct{}{}
+	}
+	go func() {
+		defer p.wg.Done()
+		if p.sem != nil {
+			defer func() { <-p.sem }()
+		}
+		fn()
+	}()
+}
+
+// wait blocks until all functions passed to run have finished
+func (p *parallelRunner) wait() {
+	p.wg.Wait()
+}
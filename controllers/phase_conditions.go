@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The phase conditions complement the Ready condition, so that it's visible which phase of the reconciliation failed
+const (
+	conditionRendered = "Rendered"
+	conditionApplied  = "Applied"
+	conditionPruned   = "Pruned"
+)
+
+// reconcilePhases tracks the progress of a single reconciliation
+type reconcilePhases struct {
+	// current is the condition type of the phase that is currently running
+	current string
+	dryRun  bool
+
+	matrixCount   int
+	renderedCount int
+	prunedCount   int
+	// prunePending is true if objects are left to be pruned by a later reconciliation
+	prunePending bool
+}
+
+func newReconcilePhases() *reconcilePhases {
+	return &reconcilePhases{
+		current: conditionRendered,
+	}
+}
+
+// setConditions sets the Rendered, Applied and Pruned conditions according to the phase in which the reconciliation
+// ended. err is the (already scrubbed) result of the reconciliation
+func (p *reconcilePhases) setConditions(rt *templatesv1alpha1.ObjectTemplate, err error) {
+	newCondition := func(t string, status metav1.ConditionStatus, reason string, message string) {
+		apimeta.SetStatusCondition(&rt.Status.Conditions, metav1.Condition{
+			Type:               t,
+			Status:             status,
+			ObservedGeneration: rt.GetGeneration(),
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+	skipped := func(t string) {
+		newCondition(t, metav1.ConditionUnknown, "Skipped", fmt.Sprintf("Skipped as the %s phase failed", p.current))
+	}
+
+	switch {
+	case p.current == conditionRendered && err != nil:
+		newCondition(conditionRendered, metav1.ConditionFalse, "RenderFailed", err.Error())
+	default:
+		newCondition(conditionRendered, metav1.ConditionTrue, "Succeeded", fmt.Sprintf("Rendered %d objects from %d matrix entries", p.renderedCount, p.matrixCount))
+	}
+
+	switch {
+	case p.current == conditionRendered:
+		skipped(conditionApplied)
+	case p.current == conditionApplied && err != nil:
+		newCondition(conditionApplied, metav1.ConditionFalse, "ApplyFailed", err.Error())
+	case p.dryRun:
+		newCondition(conditionApplied, metav1.ConditionUnknown, "DryRun", "Objects were only applied with server-side dry-run")
+	default:
+		newCondition(conditionApplied, metav1.ConditionTrue, "Succeeded", fmt.Sprintf("Applied %d objects", p.renderedCount))
+	}
+
+	switch {
+	case p.dryRun:
+		newCondition(conditionPruned, metav1.ConditionUnknown, "DryRun", "Pruning is skipped in dry-run mode")
+	case p.current != conditionPruned:
+		skipped(conditionPruned)
+	case err != nil:
+		newCondition(conditionPruned, metav1.ConditionFalse, "PruneFailed", err.Error())
+	case !rt.Spec.Prune:
+		newCondition(conditionPruned, metav1.ConditionTrue, "PruneDisabled", fmt.Sprintf("Pruning is disabled, %d objects are orphaned", len(rt.Status.OrphanedResources)))
+	default:
+		newCondition(conditionPruned, metav1.ConditionTrue, "Succeeded", fmt.Sprintf("Pruned %d objects", p.prunedCount))
+	}
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// loadPruneObjects fetches the metadata of all given objects. Objects that do not exist anymore are not part of the
+// result
+func (r *ObjectTemplateReconciler) loadPruneObjects(ctx context.Context, objClient client.Client, refs []templatesv1alpha1.ObjectRef) (map[templatesv1alpha1.ObjectRef]*metav1.PartialObjectMetadata, error) {
+	var errs *multierror.Error
+	var mutex sync.Mutex
+
+	ret := map[templatesv1alpha1.ObjectRef]*metav1.PartialObjectMetadata{}
+	runner := r.newApplyRunner()
+	for _, ref := range refs {
+		ref := ref
+		gvk, err := ref.GroupVersionKind()
+		if err != nil {
+			return nil, err
+		}
+		m := &metav1.PartialObjectMetadata{}
+		m.SetGroupVersionKind(gvk)
+		m.SetNamespace(ref.Namespace)
+		m.SetName(ref.Name)
+
+		runner.run(func() {
+			err := objClient.Get(ctx, client.ObjectKeyFromObject(m), m)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if !errors.IsNotFound(err) {
+					errs = multierror.Append(errs, err)
+				}
+				return
+			}
+			ret[ref] = m
+		})
+	}
+	runner.wait()
+
+	if errs != nil {
+		return nil, errs
+	}
+	return ret, nil
+}
+
+// isPruneDisabled returns true if pruning of the object is disabled via annotation
+func isPruneDisabled(m *metav1.PartialObjectMetadata) bool {
+	return m.GetAnnotations()[templatesv1alpha1.PruneAnnotation] == templatesv1alpha1.PruneDisabled
+}
+
+// getPruneOrder returns the prune order of the object. It defaults to the negated apply wave, so that objects are
+// deleted in the reverse order of how they were applied
+func getPruneOrder(m *metav1.PartialObjectMetadata) (int, error) {
+	if s, ok := m.GetAnnotations()[templatesv1alpha1.PruneOrderAnnotation]; ok {
+		o, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s annotation: %w", templatesv1alpha1.PruneOrderAnnotation, err)
+		}
+		return o, nil
+	}
+	if s, ok := m.GetAnnotations()[templatesv1alpha1.ApplyWaveAnnotation]; ok {
+		w, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s annotation: %w", templatesv1alpha1.ApplyWaveAnnotation, err)
+		}
+		return -w, nil
+	}
+	return 0, nil
+}
+
+// groupByPruneOrder groups the objects by their prune order. The returned groups are sorted in ascending order. Objects
+// with invalid annotations are put into the default group 0, as failing would make it impossible to ever delete them
+func groupByPruneOrder(objs []*metav1.PartialObjectMetadata) [][]*metav1.PartialObjectMetadata {
+	byOrder := map[int][]*metav1.PartialObjectMetadata{}
+	for _, m := range objs {
+		o, _ := getPruneOrder(m)
+		byOrder[o] = append(byOrder[o], m)
+	}
+
+	var keys []int
+	for k := range byOrder {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	ret := make([][]*metav1.PartialObjectMetadata, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, byOrder[k])
+	}
+	return ret
+}
+
+// deleteInPruneOrder deletes the objects group by group in ascending prune order. The next group is only deleted when
+// all objects of the previous group are gone, e.g. after their finalizers were processed. If this is not the case yet,
+// done is false and the remaining objects must be deleted in a later reconciliation. onResult is called for each
+// object that deletion was attempted for, with err being nil if the deletion succeeded
+func (r *ObjectTemplateReconciler) deleteInPruneOrder(ctx context.Context, objClient client.Client, objs []*metav1.PartialObjectMetadata, onResult func(m *metav1.PartialObjectMetadata, err error)) (done bool, err error) {
+	groups := groupByPruneOrder(objs)
+
+	runner := r.newApplyRunner()
+	for i, group := range groups {
+		var errs *multierror.Error
+		var mutex sync.Mutex
+
+		for _, m := range group {
+			m := m
+			runner.run(func() {
+				err := objClient.Delete(ctx, m)
+				if errors.IsNotFound(err) {
+					err = nil
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					errs = multierror.Append(errs, err)
+				}
+				onResult(m, err)
+			})
+		}
+		runner.wait()
+
+		if errs != nil {
+			return false, errs
+		}
+		if i == len(groups)-1 {
+			break
+		}
+
+		var refs []templatesv1alpha1.ObjectRef
+		for _, m := range group {
+			refs = append(refs, templatesv1alpha1.ObjectRefFromObject(m))
+		}
+		remaining, err := r.loadPruneObjects(ctx, objClient, refs)
+		if err != nil {
+			return false, err
+		}
+		if len(remaining) != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
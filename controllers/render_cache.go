@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sync"
+)
+
+// renderCache keeps the rendered objects of ObjectTemplates with spec.cacheRenderedObjects enabled, so that
+// templates are only rendered again when the inputs change
+type renderCache struct {
+	mutex   sync.Mutex
+	entries map[types.NamespacedName]*renderCacheEntry
+}
+
+type renderCacheEntry struct {
+	key     string
+	objects [][]renderCacheObject
+}
+
+type renderCacheObject struct {
+	obj          *unstructured.Unstructured
+	applyMode    string
+	namespace    string
+	hasNamespace bool
+}
+
+// renderCacheInputs contains everything that influences the rendered objects, except for the templates themselves
+// which are covered by the generation
+type renderCacheInputs struct {
+	UID                types.UID                    `json:"uid"`
+	Generation         int64                        `json:"generation"`
+	Labels             map[string]string            `json:"labels,omitempty"`
+	Annotations        map[string]string            `json:"annotations,omitempty"`
+	Vars               map[string]any               `json:"vars,omitempty"`
+	Matrix             []map[string]any             `json:"matrix"`
+	TemplateConfigMaps map[string]map[string]string `json:"templateConfigMaps,omitempty"`
+	ConfigMapVersions  []string                     `json:"configMapVersions,omitempty"`
+	// onCreate templates are only rendered until the initial apply succeeded
+	InitialApplySucceeded bool `json:"initialApplySucceeded,omitempty"`
+}
+
+// canCacheRenderedObjects returns true if caching is enabled and the templates do not depend on live objects
+func canCacheRenderedObjects(rt *templatesv1alpha1.ObjectTemplate) bool {
+	if !rt.Spec.CacheRenderedObjects {
+		return false
+	}
+	for _, t := range rt.Spec.Templates {
+		if t.ExistingRef != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// buildRenderCacheKey builds the cache key from all inputs of the rendering. The objectTemplate variable is only
+// covered partially (spec via generation, labels and annotations), as the status changes on every reconciliation
+func buildRenderCacheKey(rt *templatesv1alpha1.ObjectTemplate, baseVars map[string]any, matrixEntries []map[string]any, templateConfigMaps map[string]*corev1.ConfigMap, configMapVersions []string) (string, error) {
+	inputs := renderCacheInputs{
+		UID:                   rt.UID,
+		Generation:            rt.Generation,
+		Labels:                rt.Labels,
+		Annotations:           rt.Annotations,
+		Vars:                  map[string]any{},
+		Matrix:                matrixEntries,
+		TemplateConfigMaps:    map[string]map[string]string{},
+		ConfigMapVersions:     configMapVersions,
+		InitialApplySucceeded: rt.Status.InitialApplySucceeded,
+	}
+	for k, v := range baseVars {
+		// now changes on every reconciliation, so cached objects keep the timestamp of the render that created them
+		if k != "objectTemplate" && k != "now" {
+			inputs.Vars[k] = v
+		}
+	}
+	for name, cm := range templateConfigMaps {
+		inputs.TemplateConfigMaps[name] = cm.Data
+	}
+
+	b, err := json.Marshal(&inputs)
+	if err != nil {
+		return "", err
+	}
+	return Sha256Bytes(b), nil
+}
+
+// get returns deep copies of the cached objects if the key matches
+func (c *renderCache) get(name types.NamespacedName, key string) ([][]*unstructured.Unstructured, map[*unstructured.Unstructured]string, map[*unstructured.Unstructured]string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[name]
+	if !ok || e.key != key {
+		return nil, nil, nil, false
+	}
+
+	resourcesByMatrix := make([][]*unstructured.Unstructured, len(e.objects))
+	applyModes := map[*unstructured.Unstructured]string{}
+	namespaces := map[*unstructured.Unstructured]string{}
+	for i, objs := range e.objects {
+		for _, o := range objs {
+			x := o.obj.DeepCopy()
+			if o.applyMode != "" {
+				applyModes[x] = o.applyMode
+			}
+			if o.hasNamespace {
+				namespaces[x] = o.namespace
+			}
+			resourcesByMatrix[i] = append(resourcesByMatrix[i], x)
+		}
+	}
+	return resourcesByMatrix, applyModes, namespaces, true
+}
+
+// put stores deep copies of the rendered objects, which must not yet be modified after rendering
+func (c *renderCache) put(name types.NamespacedName, key string, resourcesByMatrix [][]*unstructured.Unstructured, applyModes map[*unstructured.Unstructured]string, namespaces map[*unstructured.Unstructured]string) {
+	e := &renderCacheEntry{
+		key:     key,
+		objects: make([][]renderCacheObject, len(resourcesByMatrix)),
+	}
+	for i, objs := range resourcesByMatrix {
+		for _, x := range objs {
+			ns, hasNamespace := namespaces[x]
+			e.objects[i] = append(e.objects[i], renderCacheObject{
+				obj:          x.DeepCopy(),
+				applyMode:    applyModes[x],
+				namespace:    ns,
+				hasNamespace: hasNamespace,
+			})
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = map[types.NamespacedName]*renderCacheEntry{}
+	}
+	c.entries[name] = e
+}
+
+func (c *renderCache) remove(name types.NamespacedName) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, name)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kluctl/go-jinja2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// errRenderLimitExceeded is returned when a render call exceeds one of the RenderLimits
+var errRenderLimitExceeded = errors.New("render limit exceeded")
+
+// RenderLimits restricts the resources used by a single render call, so that runaway templates (e.g. huge loops) can't
+// block reconciliations or produce unbounded output. go-jinja2 renders inside a separate Python process and does not
+// support interrupting a running render or limiting loop iterations. When the timeout is hit, the render is abandoned
+// instead and the Jinja2 instance is discarded by the jinja2Pool.
+type RenderLimits struct {
+	// MaxOutputSize is the maximum size in bytes of a single rendered template. 0 disables the limit
+	MaxOutputSize int
+
+	// Timeout is the maximum duration of a single render call. 0 disables the limit
+	Timeout time.Duration
+}
+
+// abandonedJinja2 contains the Jinja2 instances that are still busy with renders abandoned after a timeout
+var abandonedJinja2 sync.Map
+
+func isRenderLimitExceededError(err error) bool {
+	return errors.Is(err, errRenderLimitExceeded)
+}
+
+func isJinja2Abandoned(j2 *jinja2.Jinja2) bool {
+	_, ok := abandonedJinja2.Load(j2)
+	return ok
+}
+
+// run executes fn, which must render with j2, and returns an error if it did not finish within the timeout. fn keeps
+// running in the background in that case, so it must not modify any shared state. j2 is then marked as abandoned, so
+// that it is not reused
+func (l *RenderLimits) run(j2 *jinja2.Jinja2, fn func() error) error {
+	if l == nil || l.Timeout <= 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	timer := time.NewTimer(l.Timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		abandonedJinja2.Store(j2, true)
+		return fmt.Errorf("%w: rendering did not finish within %s", errRenderLimitExceeded, l.Timeout.String())
+	}
+}
+
+func (l *RenderLimits) checkOutputSize(size int) error {
+	if l == nil || l.MaxOutputSize <= 0 || size <= l.MaxOutputSize {
+		return nil
+	}
+	return fmt.Errorf("%w: rendered output has %d bytes, the maximum is %d bytes", errRenderLimitExceeded, size, l.MaxOutputSize)
+}
+
+func (l *RenderLimits) renderString(j2 *jinja2.Jinja2, template string, opts ...jinja2.Jinja2Opt) (string, error) {
+	var rendered string
+	err := l.run(j2, func() error {
+		var err error
+		rendered, err = j2.RenderString(template, opts...)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	err = l.checkOutputSize(len(rendered))
+	if err != nil {
+		return "", err
+	}
+	return rendered, nil
+}
+
+// renderStruct renders x in-place. On timeout, x must not be used anymore
+func (l *RenderLimits) renderStruct(j2 *jinja2.Jinja2, x *unstructured.Unstructured, opts ...jinja2.Jinja2Opt) error {
+	err := l.run(j2, func() error {
+		_, err := j2.RenderStruct(x, opts...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if l != nil && l.MaxOutputSize > 0 {
+		b, err := x.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		return l.checkOutputSize(len(b))
+	}
+	return nil
+}
+
+func (l *RenderLimits) evalCondition(j2 *jinja2.Jinja2, expr string, vars map[string]any, opts ...jinja2.Jinja2Opt) (bool, error) {
+	var ok bool
+	err := l.run(j2, func() error {
+		var err error
+		ok, err = EvalJinja2Condition(j2, expr, vars, opts...)
+		return err
+	})
+	return ok, err
+}
+
+func (l *RenderLimits) evalString(j2 *jinja2.Jinja2, expr string, vars map[string]any, opts ...jinja2.Jinja2Opt) (string, error) {
+	var s string
+	err := l.run(j2, func() error {
+		var err error
+		s, err = EvalJinja2String(j2, expr, vars, opts...)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return s, l.checkOutputSize(len(s))
+}
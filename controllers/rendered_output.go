@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// maxRenderedOutputSize is the maximum size of the rendered output stored in the debug ConfigMap. ConfigMaps are
+// limited to 1MiB, so we stay well below that limit
+const maxRenderedOutputSize = 256 * 1024
+
+const renderedOutputKey = "rendered.yaml"
+
+func buildRenderedOutputConfigMapName(rt *templatesv1alpha1.ObjectTemplate) string {
+	return rt.GetName() + "-rendered"
+}
+
+// buildRenderedOutput serializes all objects into a multi-document YAML string. Data of Secrets is redacted, as the
+// output is stored in a ConfigMap
+func buildRenderedOutput(objs []*unstructured.Unstructured, scrubber *secretScrubber) (string, error) {
+	var buf bytes.Buffer
+	for _, x := range objs {
+		if isSecret(x) {
+			x = x.DeepCopy()
+			for _, f := range []string{"data", "stringData"} {
+				m, ok, _ := unstructured.NestedMap(x.Object, f)
+				if !ok {
+					continue
+				}
+				for k := range m {
+					m[k] = "*****"
+				}
+				_ = unstructured.SetNestedMap(x.Object, m, f)
+			}
+		}
+
+		b, err := yaml.Marshal(x.Object)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString("---\n")
+		buf.Write(b)
+	}
+
+	s := scrubber.Scrub(buf.String())
+	if len(s) > maxRenderedOutputSize {
+		omitted := len(s) - maxRenderedOutputSize
+		// cutting might split a multi-byte character
+		s = strings.ToValidUTF8(s[:maxRenderedOutputSize], "") + fmt.Sprintf("\n# ... output truncated, %d bytes omitted\n", omitted)
+	}
+	return s, nil
+}
+
+// storeRenderedOutput writes the rendered output into a ConfigMap that is owned by the ObjectTemplate, so that it
+// gets garbage collected together with the ObjectTemplate
+func (r *ObjectTemplateReconciler) storeRenderedOutput(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, objs []*unstructured.Unstructured, scrubber *secretScrubber) error {
+	output, err := buildRenderedOutput(objs, scrubber)
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{}
+	cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	cm.SetNamespace(rt.GetNamespace())
+	cm.SetName(buildRenderedOutputConfigMapName(rt))
+	cm.Data = map[string]string{
+		renderedOutputKey: output,
+	}
+	err = controllerutil.SetOwnerReference(rt, cm, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	err = objClient.Patch(ctx, cm, client.Apply, client.FieldOwner(r.getFieldManager(rt)), client.ForceOwnership)
+	if err != nil {
+		return fmt.Errorf("failed to store rendered output: %w", err)
+	}
+	return nil
+}
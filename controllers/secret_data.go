@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func isSecret(x *unstructured.Unstructured) bool {
+	return x.GroupVersionKind().GroupKind() == corev1.SchemeGroupVersion.WithKind("Secret").GroupKind()
+}
+
+// normalizeRenderedSecret moves the values of stringData into data, base64 encoding them on the way. Values from
+// stringData take precedence, the same way as the API server handles it. This avoids ownership of the write-only
+// stringData field and lets drift detection and hashing see the real content of the Secret. All values are added to
+// the scrubber so that these never end up in the status or in events
+func normalizeRenderedSecret(x *unstructured.Unstructured, scrubber *secretScrubber) error {
+	if !isSecret(x) {
+		return nil
+	}
+
+	data, _, err := unstructured.NestedMap(x.Object, "data")
+	if err != nil {
+		return fmt.Errorf("invalid data in %s: %w", renderedObjectString(x), err)
+	}
+	for k, v := range data {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("data.%s of %s must be a string", k, renderedObjectString(x))
+		}
+		scrubber.AddString(s)
+		if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+			scrubber.AddString(string(decoded))
+		}
+	}
+
+	stringData, ok, err := unstructured.NestedMap(x.Object, "stringData")
+	if err != nil {
+		return fmt.Errorf("invalid stringData in %s: %w", renderedObjectString(x), err)
+	}
+	if !ok {
+		return nil
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	for k, v := range stringData {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("stringData.%s of %s must be a string", k, renderedObjectString(x))
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(s))
+		scrubber.AddString(s)
+		scrubber.AddString(encoded)
+		data[k] = encoded
+	}
+	unstructured.RemoveNestedField(x.Object, "stringData")
+	return unstructured.SetNestedMap(x.Object, data, "data")
+}
@@ -25,12 +25,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
@@ -64,6 +62,11 @@ func (r *TextTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return
 	}
 
+	// the template might have been moved to another shard in the meantime
+	if !r.isInShard(&tt) {
+		return
+	}
+
 	// Return early if the object is suspended.
 	if tt.Spec.Suspend {
 		logger.Info("Reconciliation is suspended for this object")
@@ -222,9 +225,7 @@ func (r *TextTemplateReconciler) SetupWithManager(mgr ctrl.Manager, concurrent i
 	}
 
 	c, err := ctrl.NewControllerManagedBy(mgr).
-		For(&templatesv1alpha1.TextTemplate{}, builder.WithPredicates(
-			predicate.Or(predicate.GenerationChangedPredicate{}),
-		)).
+		For(&templatesv1alpha1.TextTemplate{}, r.buildForPredicates()).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: concurrent,
 		}).
@@ -238,7 +239,7 @@ func (r *TextTemplateReconciler) SetupWithManager(mgr ctrl.Manager, concurrent i
 }
 
 func (r *TextTemplateReconciler) buildWatchEventHandler(indexField string) handler.EventHandler {
-	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
+	return enqueueRequestsFromMapFuncDelayed(func(ctx context.Context, object client.Object) []reconcile.Request {
 		var list templatesv1alpha1.TextTemplateList
 
 		err := r.List(ctx, &list, client.MatchingFields{
@@ -249,6 +250,9 @@ func (r *TextTemplateReconciler) buildWatchEventHandler(indexField string) handl
 		}
 		var reqs []reconcile.Request
 		for _, x := range list.Items {
+			if !r.isInShard(&x) {
+				continue
+			}
 			reqs = append(reqs, reconcile.Request{
 				NamespacedName: types.NamespacedName{
 					Namespace: x.GetNamespace(),
@@ -257,7 +261,7 @@ func (r *TextTemplateReconciler) buildWatchEventHandler(indexField string) handl
 			})
 		}
 		return reqs
-	})
+	}, r.WatchDelay)
 }
 
 func (r *TextTemplateReconciler) buildTemplateRef(tt *templatesv1alpha1.TextTemplate) *templatesv1alpha1.ObjectRef {
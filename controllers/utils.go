@@ -4,12 +4,21 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/kluctl/go-jinja2"
+	"github.com/kluctl/kluctl/v2/pkg/diff"
+	"github.com/kluctl/kluctl/v2/pkg/utils/uo"
 	"github.com/kluctl/template-controller/api/v1alpha1"
+	"gomodules.xyz/jsonpatch/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"sync"
 )
 
 func NewJinja2(opts ...jinja2.Jinja2Opt) (*jinja2.Jinja2, error) {
@@ -24,6 +33,27 @@ func NewJinja2(opts ...jinja2.Jinja2Opt) (*jinja2.Jinja2, error) {
 	return jinja2.NewJinja2("template-controller", 1, opts2...)
 }
 
+// EvalJinja2Condition evaluates the given Jinja2 expression and returns true if the result is truthy
+func EvalJinja2Condition(j2 *jinja2.Jinja2, expr string, vars map[string]any, opts ...jinja2.Jinja2Opt) (bool, error) {
+	opts = append(opts[:len(opts):len(opts)], jinja2.WithGlobals(vars))
+	r, err := j2.RenderString(fmt.Sprintf("{%% if %s %%}true{%% endif %%}", expr), opts...)
+	if err != nil {
+		return false, err
+	}
+	return r == "true", nil
+}
+
+// EvalJinja2String evaluates the given Jinja2 expression and returns the result as string, with surrounding whitespace
+// removed
+func EvalJinja2String(j2 *jinja2.Jinja2, expr string, vars map[string]any, opts ...jinja2.Jinja2Opt) (string, error) {
+	opts = append(opts[:len(opts):len(opts)], jinja2.WithGlobals(vars))
+	r, err := j2.RenderString(fmt.Sprintf("{{ %s }}", expr), opts...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(r), nil
+}
+
 func MergeMap(a, b map[string]interface{}) {
 	MergeMap2(a, b, false)
 }
@@ -77,6 +107,28 @@ func GetSecretToken(ctx context.Context, client client.Client, namespace string,
 	return token, nil
 }
 
+// DiffObjects compares both objects after normalizing them and returns the JSON paths of all changed fields. Values
+// are intentionally not returned as these might contain sensitive data.
+func DiffObjects(oldObj *unstructured.Unstructured, newObj *unstructured.Unstructured) ([]string, error) {
+	o1, err := diff.NormalizeObject(uo.FromUnstructured(oldObj), nil, uo.FromUnstructured(newObj))
+	if err != nil {
+		return nil, err
+	}
+	o2, err := diff.NormalizeObject(uo.FromUnstructured(newObj), nil, uo.FromUnstructured(newObj))
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diff.Diff(o1, o2)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, c := range changes {
+		ret = append(ret, c.JsonPath)
+	}
+	return ret, nil
+}
+
 type SubResourceFieldOwner string
 
 func (f SubResourceFieldOwner) ApplyToSubResourceUpdate(opts *client.SubResourceUpdateOptions) {
@@ -86,3 +138,135 @@ func (f SubResourceFieldOwner) ApplyToSubResourceUpdate(opts *client.SubResource
 func (f SubResourceFieldOwner) ApplyToSubResourcePatch(opts *client.SubResourcePatchOptions) {
 	opts.FieldManager = string(f)
 }
+
+// secretScrubber collects sensitive values and removes them from messages before these are written to logs or status
+type secretScrubber struct {
+	mutex  sync.Mutex
+	values []string
+}
+
+// minScrubLength is the minimum length of values that are scrubbed wherever they appear. Shorter values like "1" or
+// "false" are only scrubbed as whole words, as they would otherwise mangle unrelated parts of messages
+const minScrubLength = 6
+
+func (s *secretScrubber) AddString(v string) {
+	if v == "" {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values = append(s.values, v)
+	// replace longer values first so that values containing other values are fully scrubbed
+	sort.SliceStable(s.values, func(i, j int) bool {
+		return len(s.values[i]) > len(s.values[j])
+	})
+}
+
+// AddValue adds all string leafs of the given (parsed YAML/JSON) value
+func (s *secretScrubber) AddValue(v any) {
+	switch x := v.(type) {
+	case string:
+		s.AddString(x)
+	case map[string]any:
+		for _, e := range x {
+			s.AddValue(e)
+		}
+	case []any:
+		for _, e := range x {
+			s.AddValue(e)
+		}
+	}
+}
+
+func (s *secretScrubber) Scrub(msg string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, v := range s.values {
+		if len(v) < minScrubLength {
+			msg = replaceWholeWord(msg, v, "*****")
+		} else {
+			msg = strings.ReplaceAll(msg, v, "*****")
+		}
+	}
+	return msg
+}
+
+// replaceWholeWord replaces all occurrences of old that are neither preceded nor followed by a letter, digit or
+// underscore
+func replaceWholeWord(s string, old string, new string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, old)
+		if i == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(old)
+		if (i == 0 || !isWordByte(s[i-1])) && (end == len(s) || !isWordByte(s[end])) {
+			b.WriteString(s[:i])
+			b.WriteString(new)
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (s *secretScrubber) ScrubError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := s.Scrub(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &scrubbedError{msg: msg, err: err}
+}
+
+// scrubbedError replaces the message of the wrapped error while keeping it available for errors.Is and errors.As
+type scrubbedError struct {
+	msg string
+	err error
+}
+
+func (e *scrubbedError) Error() string {
+	return e.msg
+}
+
+func (e *scrubbedError) Unwrap() error {
+	return e.err
+}
+
+// buildJsonPatch returns a JSON patch that merges rendered into live. The patch includes a test operation for the
+// resourceVersion, so that concurrent modifications are detected. Returns nil if nothing would change.
+func buildJsonPatch(live *unstructured.Unstructured, rendered *unstructured.Unstructured) ([]byte, error) {
+	merged := live.DeepCopy()
+	MergeMap(merged.Object, runtime.DeepCopyJSON(rendered.Object))
+
+	liveJson, err := json.Marshal(live.Object)
+	if err != nil {
+		return nil, err
+	}
+	mergedJson, err := json.Marshal(merged.Object)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := jsonpatch.CreatePatch(liveJson, mergedJson)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	ops = append([]jsonpatch.Operation{{
+		Operation: "test",
+		Path:      "/metadata/resourceVersion",
+		Value:     live.GetResourceVersion(),
+	}}, ops...)
+	return json.Marshal(ops)
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScrubErrorKeepsWrappedError(t *testing.T) {
+	scrubber := &secretScrubber{}
+	scrubber.AddValue(map[string]any{"password": "s3cr3t", "replicas": "1", "enabled": "false"})
+
+	err := scrubber.ScrubError(fmt.Errorf("rendering with s3cr3t failed: %w", errRenderLimitExceeded))
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("secret value was not scrubbed: %s", err.Error())
+	}
+	if !isRenderLimitExceededError(err) {
+		t.Errorf("scrubbed error does not wrap the original error anymore")
+	}
+
+	msg := scrubber.Scrub("scaled to 1 replicas, enabled=false, 10 pods, disabled=falsey")
+	if msg != "scaled to ***** replicas, enabled=*****, 10 pods, disabled=falsey" {
+		t.Errorf("short values must be scrubbed as whole words: %s", msg)
+	}
+}
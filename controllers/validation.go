@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateRenderedObjects performs basic validation of all rendered objects and returns all found problems at once
+func validateRenderedObjects(objs []*unstructured.Unstructured) error {
+	var errs *multierror.Error
+	for i, x := range objs {
+		var missing []string
+		if x.GetAPIVersion() == "" {
+			missing = append(missing, "apiVersion")
+		}
+		if x.GetKind() == "" {
+			missing = append(missing, "kind")
+		}
+		if x.GetName() == "" {
+			missing = append(missing, "metadata.name")
+		}
+		if len(missing) != 0 {
+			errs = multierror.Append(errs, fmt.Errorf("rendered object %d (%s) is missing %v", i, renderedObjectString(x), missing))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// deduplicateRenderedObjects removes objects that were rendered multiple times with identical content. Objects that
+// were rendered multiple times with different content result in an error that lists the conflicting matrix entries.
+func deduplicateRenderedObjects(objs []*unstructured.Unstructured, matrixIndexes map[*unstructured.Unstructured]int) ([]*unstructured.Unstructured, error) {
+	firstByRef := map[templatesv1alpha1.ObjectRef]*unstructured.Unstructured{}
+	var ret []*unstructured.Unstructured
+	var errs *multierror.Error
+	for _, x := range objs {
+		ref := templatesv1alpha1.ObjectRefFromObject(x)
+		ref = ref.WithoutVersion()
+		first, ok := firstByRef[ref]
+		if !ok {
+			firstByRef[ref] = x
+			ret = append(ret, x)
+			continue
+		}
+		if !equality.Semantic.DeepEqual(first.Object, x.Object) {
+			errs = multierror.Append(errs, fmt.Errorf("%s is rendered with conflicting content by matrix entries %d and %d",
+				renderedObjectString(x), matrixIndexes[first], matrixIndexes[x]))
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return ret, nil
+}
+
+func renderedObjectString(x *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s %s", x.GetAPIVersion(), x.GetKind(), x.GetName())
+}
+
+// validateObjectsSchema validates all objects against the OpenAPI schema of their CRDs. Objects with kinds that are
+// not backed by a CRD are skipped.
+func validateObjectsSchema(ctx context.Context, c client.Client, objs []*unstructured.Unstructured) error {
+	validators := map[string]*validate.SchemaValidator{}
+
+	var errs *multierror.Error
+	for _, x := range objs {
+		gvk := x.GroupVersionKind()
+		key := gvk.String()
+		v, ok := validators[key]
+		if !ok {
+			var err error
+			v, err = buildCrdSchemaValidator(ctx, c, x)
+			if err != nil {
+				return err
+			}
+			validators[key] = v
+		}
+		if v == nil {
+			continue
+		}
+
+		res := v.Validate(x.Object)
+		for _, err := range res.Errors {
+			errs = multierror.Append(errs, fmt.Errorf("%s is invalid: %w", renderedObjectString(x), err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// buildCrdSchemaValidator returns nil if no CRD (or no schema) is found for the object's kind
+func buildCrdSchemaValidator(ctx context.Context, c client.Client, x *unstructured.Unstructured) (*validate.SchemaValidator, error) {
+	gvk := x.GroupVersionKind()
+	if gvk.Group == "" {
+		return nil, nil
+	}
+
+	rm, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	var crd apiextensionsv1.CustomResourceDefinition
+	err = c.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("%s.%s", rm.Resource.Resource, gvk.Group)}, &crd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, v := range crd.Spec.Versions {
+		if v.Name != gvk.Version {
+			continue
+		}
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			return nil, nil
+		}
+
+		// CRD schemas are self-contained (no references), so they can be converted via JSON
+		b, err := json.Marshal(v.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return nil, err
+		}
+		var s spec.Schema
+		err = json.Unmarshal(b, &s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert schema of CRD %s: %w", crd.Name, err)
+		}
+		return validate.NewSchemaValidator(&s, nil, "", strfmt.Default), nil
+	}
+	return nil, nil
+}
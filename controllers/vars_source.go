@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// buildVars loads all variables specified in spec.vars. Variables loaded from ConfigMaps and Secrets are merged first,
+// inline values are merged afterwards so that they take precedence
+func (r *ObjectTemplateReconciler) buildVars(ctx context.Context, objClient client.Client, rt *templatesv1alpha1.ObjectTemplate, scrubber *secretScrubber) (map[string]any, error) {
+	var loaded []map[string]any
+	var inline []map[string]any
+	for i, vs := range rt.Spec.Vars {
+		if vs.Values != nil {
+			var m map[string]any
+			err := json.Unmarshal(vs.Values.Raw, &m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse values of vars entry %d: %w", i, err)
+			}
+			inline = append(inline, m)
+			continue
+		}
+
+		var elems []any
+		var err error
+		var ref templatesv1alpha1.ObjectRef
+		if vs.ConfigMap != nil {
+			ref = templatesv1alpha1.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Name: vs.ConfigMap.Name}
+			elems, err = r.buildConfigMapInput(ctx, objClient, rt.GetNamespace(), ref, vs.ConfigMap.Key, false)
+		} else if vs.Secret != nil {
+			ref = templatesv1alpha1.ObjectRef{APIVersion: "v1", Kind: "Secret", Name: vs.Secret.Name}
+			elems, err = r.buildSecretInput(ctx, objClient, rt.GetNamespace(), ref, vs.Secret.Key, false, scrubber)
+		} else {
+			return nil, fmt.Errorf("missing source for vars entry %d", i)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load vars entry %d: %w", i, err)
+		}
+		m, ok := elems[0].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("vars entry %d: %s does not contain a map", i, ref.String())
+		}
+		loaded = append(loaded, m)
+	}
+	return mergeVars(loaded, inline), nil
+}
+
+// mergeVars merges the loaded vars in order and then the inline vars on top of them
+func mergeVars(loaded []map[string]any, inline []map[string]any) map[string]any {
+	ret := map[string]any{}
+	for _, m := range loaded {
+		MergeMap(ret, runtime.DeepCopyJSON(m))
+	}
+	for _, m := range inline {
+		MergeMap(ret, runtime.DeepCopyJSON(m))
+	}
+	return ret
+}
+
+// mergeBaseVars returns the user provided vars with the base vars (e.g. `objectTemplate`) on top. Base vars always
+// replace vars with the same name
+func mergeBaseVars(vars map[string]any, baseVars map[string]any) map[string]any {
+	ret := runtime.DeepCopyJSON(vars)
+	for k, v := range baseVars {
+		ret[k] = v
+	}
+	return ret
+}
+
+// buildMatrixVars returns a copy of baseVars with `matrix` set to the given matrix entry. The matrix entry always
+// replaces a variable with the same name
+func buildMatrixVars(baseVars map[string]any, matrix map[string]any) map[string]any {
+	vars := runtime.DeepCopyJSON(baseVars)
+	vars["matrix"] = matrix
+	return vars
+}
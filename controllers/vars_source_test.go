@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMergeVarsPrecedence(t *testing.T) {
+	loaded := []map[string]any{
+		{"cluster": map[string]any{"name": "from-cm", "region": "eu"}, "a": "cm1"},
+		{"a": "cm2"},
+	}
+	inline := []map[string]any{
+		{"cluster": map[string]any{"name": "inline"}},
+	}
+
+	vars := mergeVars(loaded, inline)
+	expected := map[string]any{
+		"cluster": map[string]any{"name": "inline", "region": "eu"},
+		"a":       "cm2",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("unexpected vars: %v", vars)
+	}
+}
+
+func TestBaseAndMatrixVarsPrecedence(t *testing.T) {
+	vars := map[string]any{
+		"objectTemplate": "from-vars",
+		"matrix":         map[string]any{"x": "from-vars", "y": "from-vars"},
+		"region":         "eu",
+	}
+	baseVars := mergeBaseVars(vars, map[string]any{
+		"objectTemplate": map[string]any{"kind": "ObjectTemplate"},
+	})
+	if !reflect.DeepEqual(baseVars["objectTemplate"], map[string]any{"kind": "ObjectTemplate"}) {
+		t.Errorf("objectTemplate must not be overridden by vars: %v", baseVars["objectTemplate"])
+	}
+
+	m := buildMatrixVars(baseVars, map[string]any{"x": "from-matrix"})
+	if !reflect.DeepEqual(m["matrix"], map[string]any{"x": "from-matrix"}) {
+		t.Errorf("matrix must fully replace vars with the same name: %v", m["matrix"])
+	}
+	if m["region"] != "eu" {
+		t.Errorf("expected region from vars, got %v", m["region"])
+	}
+	if !reflect.DeepEqual(baseVars["matrix"], vars["matrix"]) {
+		t.Errorf("baseVars must not be modified: %v", baseVars["matrix"])
+	}
+}
+
+func TestBuildVarsFromSources(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cm"},
+		Data:       map[string]string{"vars": "cluster: c1\nregion: eu\n"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "secret"},
+		Data: map[string][]byte{
+			"vars":    []byte("password: s3cr3t\n"),
+			"invalid": []byte("- s3cr3t\n"),
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(cm, secret).Build()
+	r := &ObjectTemplateReconciler{}
+
+	inline, _ := json.Marshal(map[string]any{"region": "us"})
+	rt := &templatesv1alpha1.ObjectTemplate{}
+	rt.Namespace = "ns"
+	rt.Spec.Vars = []*templatesv1alpha1.VarsSource{
+		{Values: &runtime.RawExtension{Raw: inline}},
+		{ConfigMap: &templatesv1alpha1.VarsSourceRef{Name: "cm", Key: "vars"}},
+		{Secret: &templatesv1alpha1.VarsSourceRef{Name: "secret", Key: "vars"}},
+	}
+
+	scrubber := &secretScrubber{}
+	vars, err := r.buildVars(context.Background(), c, rt, scrubber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"cluster": "c1", "region": "us", "password": "s3cr3t"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("unexpected vars: %v", vars)
+	}
+
+	rt.Spec.Vars = []*templatesv1alpha1.VarsSource{
+		{Secret: &templatesv1alpha1.VarsSourceRef{Name: "secret", Key: "invalid"}},
+	}
+	scrubber = &secretScrubber{}
+	_, err = r.buildVars(context.Background(), c, rt, scrubber)
+	if err == nil {
+		t.Fatal("expected error for secret that does not contain a map")
+	}
+	if msg := scrubber.ScrubError(fmt.Errorf("rendering failed: %s", "s3cr3t")).Error(); strings.Contains(msg, "s3cr3t") {
+		t.Errorf("secret value was not scrubbed: %s", msg)
+	}
+}
@@ -1,11 +1,18 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"time"
 )
 
+// BuildRefIndexValue builds the index value for the referenced object. ns is used for refs without an explicit
+// namespace, so that the value matches BuildObjectIndexValue of the referenced object, even across namespaces
 func BuildRefIndexValue(ref templatesv1alpha1.ObjectRef, ns string) string {
 	if ref.Namespace != "" {
 		ns = ref.Namespace
@@ -17,3 +24,44 @@ func BuildObjectIndexValue(obj client.Object) string {
 	gvk := obj.GetObjectKind().GroupVersionKind()
 	return fmt.Sprintf("%s/%s/%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
 }
+
+func BuildKindNamespaceIndexValue(kind string, ns string) string {
+	return fmt.Sprintf("%s/%s", kind, ns)
+}
+
+func BuildObjectKindNamespaceIndexValue(obj client.Object) string {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return BuildKindNamespaceIndexValue(gvk.Kind, obj.GetNamespace())
+}
+
+// enqueueRequestsFromMapFuncDelayed works like handler.EnqueueRequestsFromMapFunc, but delays the enqueued requests.
+// The delaying queue coalesces requests for the same object, so that a burst of events on watched objects results in
+// a single reconciliation. A delay of 0 enqueues the requests immediately
+func enqueueRequestsFromMapFuncDelayed(fn handler.MapFunc, delay time.Duration) handler.EventHandler {
+	if delay <= 0 {
+		return handler.EnqueueRequestsFromMapFunc(fn)
+	}
+	add := func(ctx context.Context, obj client.Object, q workqueue.RateLimitingInterface) {
+		if obj == nil {
+			return
+		}
+		for _, req := range fn(ctx, obj) {
+			q.AddAfter(req, delay)
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			add(ctx, e.Object, q)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			add(ctx, e.ObjectOld, q)
+			add(ctx, e.ObjectNew, q)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			add(ctx, e.Object, q)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			add(ctx, e.Object, q)
+		},
+	}
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func buildTestObject(kind string, namespace string, name string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{}
+	o.SetAPIVersion("v1")
+	o.SetKind(kind)
+	o.SetNamespace(namespace)
+	o.SetName(name)
+	return o
+}
+
+func buildCrossNamespaceTemplate() *templatesv1alpha1.ObjectTemplate {
+	rt := &templatesv1alpha1.ObjectTemplate{}
+	rt.Namespace = "ns1"
+	rt.Name = "rt"
+	rt.Spec.Matrix = []*templatesv1alpha1.MatrixEntry{
+		{Name: "same", Object: &templatesv1alpha1.MatrixEntryObject{
+			Ref: templatesv1alpha1.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Name: "cm1"},
+		}},
+		{Name: "other", Object: &templatesv1alpha1.MatrixEntryObject{
+			Ref: templatesv1alpha1.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns2", Name: "cm2"},
+		}},
+		{Name: "secret", Secret: &templatesv1alpha1.MatrixEntrySecret{
+			Ref: templatesv1alpha1.NamespacedObjectReference{Namespace: "ns3", Name: "secret"},
+		}},
+		{Name: "templated", Object: &templatesv1alpha1.MatrixEntryObject{
+			Ref: templatesv1alpha1.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns4", Name: "{{ matrix.same.data.name }}"},
+		}},
+	}
+	return rt
+}
+
+func TestMatrixObjectIndexValuesCrossNamespace(t *testing.T) {
+	r := &ObjectTemplateReconciler{}
+	rt := buildCrossNamespaceTemplate()
+
+	values := r.buildMatrixObjectIndexValues(rt)
+	expected := []string{
+		BuildObjectIndexValue(buildTestObject("ConfigMap", "ns1", "cm1")),
+		BuildObjectIndexValue(buildTestObject("ConfigMap", "ns2", "cm2")),
+		BuildObjectIndexValue(buildTestObject("Secret", "ns3", "secret")),
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected index values: %v, expected %v", values, expected)
+	}
+
+	values = r.buildMatrixObjectListIndexValues(rt)
+	expected = []string{
+		BuildObjectKindNamespaceIndexValue(buildTestObject("ConfigMap", "ns4", "any")),
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected list index values: %v, expected %v", values, expected)
+	}
+}
+
+func TestWatchEventHandlerCrossNamespace(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = scheme.AddToScheme(s)
+	_ = templatesv1alpha1.AddToScheme(s)
+
+	r := &ObjectTemplateReconciler{}
+	rt := buildCrossNamespaceTemplate()
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(rt).
+		WithIndex(&templatesv1alpha1.ObjectTemplate{}, forMatrixObjectKey, func(o client.Object) []string {
+			return r.buildMatrixObjectIndexValues(o.(*templatesv1alpha1.ObjectTemplate))
+		}).
+		WithIndex(&templatesv1alpha1.ObjectTemplate{}, forMatrixObjectListKey, func(o client.Object) []string {
+			return r.buildMatrixObjectListIndexValues(o.(*templatesv1alpha1.ObjectTemplate))
+		}).
+		Build()
+	r.Client = c
+
+	enqueued := func(key string, buildIndexValue func(obj client.Object) string, obj client.Object) []reconcile.Request {
+		q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer q.ShutDown()
+		r.buildWatchEventHandler(key, buildIndexValue).Create(context.Background(), event.CreateEvent{Object: obj}, q)
+		var ret []reconcile.Request
+		for q.Len() != 0 {
+			item, _ := q.Get()
+			ret = append(ret, item.(reconcile.Request))
+			q.Done(item)
+		}
+		sort.Slice(ret, func(i, j int) bool {
+			return ret[i].String() < ret[j].String()
+		})
+		return ret
+	}
+	expected := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "rt"}}}
+
+	tests := []struct {
+		name     string
+		key      string
+		obj      *unstructured.Unstructured
+		expected []reconcile.Request
+	}{
+		{name: "same namespace", key: forMatrixObjectKey, obj: buildTestObject("ConfigMap", "ns1", "cm1"), expected: expected},
+		{name: "other namespace", key: forMatrixObjectKey, obj: buildTestObject("ConfigMap", "ns2", "cm2"), expected: expected},
+		{name: "other namespace secret", key: forMatrixObjectKey, obj: buildTestObject("Secret", "ns3", "secret"), expected: expected},
+		{name: "wrong namespace", key: forMatrixObjectKey, obj: buildTestObject("ConfigMap", "ns1", "cm2")},
+		{name: "templated ref", key: forMatrixObjectListKey, obj: buildTestObject("ConfigMap", "ns4", "any"), expected: expected},
+		{name: "templated ref wrong namespace", key: forMatrixObjectListKey, obj: buildTestObject("ConfigMap", "ns1", "any")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buildIndexValue := BuildObjectIndexValue
+			if tc.key == forMatrixObjectListKey {
+				buildIndexValue = BuildObjectKindNamespaceIndexValue
+			}
+			reqs := enqueued(tc.key, buildIndexValue, tc.obj)
+			if !reflect.DeepEqual(reqs, tc.expected) {
+				t.Errorf("unexpected requests: %v, expected %v", reqs, tc.expected)
+			}
+		})
+	}
+}
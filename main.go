@@ -18,12 +18,15 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/kluctl/template-controller/controllers"
 	"github.com/kluctl/template-controller/controllers/comments"
 	"os"
 	"path/filepath"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"strings"
+	"time"
 
 	"github.com/kluctl/template-controller/controllers/objecthandler"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -45,6 +48,9 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+
+	// version is set via ldflags by goreleaser
+	version = "0.0.0"
 )
 
 func init() {
@@ -56,11 +62,27 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(runRender(os.Args[2:], os.Stdout))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var watchAllNamespaces bool
+	var watchNamespacesStr string
 	var concurrent int
+	var applyConcurrency int
+	var enableCustomJinja2Filters bool
+	var enableWebhooks bool
+	var allowedKinds string
+	var deniedKinds string
+	var renderTimeout time.Duration
+	var maxRenderOutputSize int
+	var watchDelay time.Duration
+	var shard string
+	var maxResources int
+	var intervalJitter float64
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -68,7 +90,43 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&watchAllNamespaces, "watch-all-namespaces", true,
 		"Watch for custom resources in all namespaces, if set to false it will only watch the runtime namespace.")
+	flag.StringVar(&watchNamespacesStr, "watch-namespaces", "",
+		"Comma separated list of namespaces to watch for custom resources. Templates are also restricted to read and "+
+			"apply objects only in these namespaces. Overrides --watch-all-namespaces.")
 	flag.IntVar(&concurrent, "concurrent", 4, "The number of concurrent reconciliations for each type.")
+	flag.IntVar(&applyConcurrency, "apply-concurrency", 10,
+		"The maximum number of objects that are applied or deleted in parallel by a single ObjectTemplate reconciliation.")
+	flag.BoolVar(&enableCustomJinja2Filters, "enable-custom-jinja2-filters", false,
+		"Allow ObjectTemplates to load custom Jinja2 filters from ConfigMaps. Custom filters are Python code executed "+
+			"inside the controller process, only enable this if all users creating ObjectTemplates are trusted.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the validating admission webhook for ObjectTemplates. Requires a serving certificate to be mounted "+
+			"into the controller.")
+	flag.StringVar(&allowedKinds, "allowed-kinds", "",
+		"Comma separated list of kinds that ObjectTemplates are allowed to apply, in the form Kind.group (e.g. "+
+			"ConfigMap or Deployment.apps). Wildcards are supported (e.g. *.apps). If empty, all kinds are allowed.")
+	flag.StringVar(&deniedKinds, "denied-kinds", "",
+		"Comma separated list of kinds that ObjectTemplates are not allowed to apply, in the same form as "+
+			"--allowed-kinds (e.g. *.rbac.authorization.k8s.io). Takes precedence over --allowed-kinds.")
+	flag.DurationVar(&renderTimeout, "render-timeout", time.Minute,
+		"The maximum duration of rendering a single template of an ObjectTemplate. Set to 0 to disable the limit.")
+	flag.IntVar(&maxRenderOutputSize, "max-render-output-size", 10*1024*1024,
+		"The maximum size in bytes of the output of rendering a single template of an ObjectTemplate. Set to 0 to "+
+			"disable the limit.")
+	flag.DurationVar(&watchDelay, "watch-delay", time.Second,
+		"Delays reconciliations triggered by changes of watched objects (e.g. matrix inputs), so that a burst of "+
+			"changes results in a single reconciliation. Set to 0 to reconcile immediately.")
+	flag.IntVar(&maxResources, "max-resources", 10000,
+		"The maximum number of objects a single ObjectTemplate may render. ObjectTemplates exceeding this limit fail "+
+			"before anything is applied. Set to 0 to disable the limit.")
+	flag.Float64Var(&intervalJitter, "interval-jitter", 0.05,
+		"Randomizes the reconciliation interval of ObjectTemplates and MatrixTextTemplates by up to the given fraction "+
+			"in both directions (e.g. 0.05 means ±5%), so that templates with the same interval don't reconcile in "+
+			"lockstep. Must be between 0 and 1, set to 0 to disable jitter.")
+	flag.StringVar(&shard, "shard", "",
+		"Only reconcile ObjectTemplates, TextTemplates and MatrixTextTemplates with a matching "+
+			templatesv1alpha1.ShardLabel+" label. If empty, only templates without this label are reconciled. All "+
+			"other controllers are only run when no shard is specified.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,18 +135,46 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	watchNamespace := ""
-	if !watchAllNamespaces {
-		watchNamespace = os.Getenv("RUNTIME_NAMESPACE")
+	var watchNamespaces []string
+	if watchNamespacesStr != "" {
+		for _, ns := range strings.Split(watchNamespacesStr, ",") {
+			ns = strings.TrimSpace(ns)
+			if ns != "" {
+				watchNamespaces = append(watchNamespaces, ns)
+			}
+		}
+	} else if !watchAllNamespaces {
+		watchNamespace := os.Getenv("RUNTIME_NAMESPACE")
+		if watchNamespace != "" {
+			watchNamespaces = append(watchNamespaces, watchNamespace)
+		}
+	}
+
+	if intervalJitter < 0 || intervalJitter >= 1 {
+		setupLog.Error(fmt.Errorf("must be between 0 and 1"), "invalid interval jitter", "intervalJitter", intervalJitter)
+		os.Exit(1)
+	}
+
+	kindPolicy, err := controllers.ParseKindPolicy(allowedKinds, deniedKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid kind policy")
+		os.Exit(1)
 	}
 
 	var cacheNamespaces map[string]cache.Config
-	if watchNamespace != "" {
-		cacheNamespaces = map[string]cache.Config{
-			watchNamespace: {},
+	if len(watchNamespaces) != 0 {
+		cacheNamespaces = map[string]cache.Config{}
+		for _, ns := range watchNamespaces {
+			cacheNamespaces[ns] = cache.Config{}
 		}
 	}
 
+	leaderElectionID := "3ab68de8.kluctl.io"
+	if shard != "" {
+		// each shard needs its own leader
+		leaderElectionID = fmt.Sprintf("%s.%s", shard, leaderElectionID)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
@@ -96,7 +182,7 @@ func main() {
 		},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "3ab68de8.kluctl.io",
+		LeaderElectionID:       leaderElectionID,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -118,79 +204,133 @@ func main() {
 	}
 
 	fieldManager := "template-controller"
+	controllerName := "template-controller"
 
 	if err = (&controllers.ObjectTemplateReconciler{
 		BaseTemplateReconciler: controllers.BaseTemplateReconciler{
-			Client:       mgr.GetClient(),
-			Scheme:       mgr.GetScheme(),
-			FieldManager: fieldManager,
+			Client:            mgr.GetClient(),
+			Scheme:            mgr.GetScheme(),
+			FieldManager:      fieldManager,
+			ControllerName:    controllerName,
+			ControllerVersion: version,
+			AllowedNamespaces: watchNamespaces,
+			WatchDelay:        watchDelay,
+			Shard:             shard,
+			IntervalJitter:    intervalJitter,
+		},
+		EventRecorder:             mgr.GetEventRecorderFor("template-controller"),
+		EnableCustomJinja2Filters: enableCustomJinja2Filters,
+		ApplyConcurrency:          applyConcurrency,
+		KindPolicy:                kindPolicy,
+		TmpBaseDir:                filepath.Join(os.TempDir(), "template-controller"),
+		RenderLimits: &controllers.RenderLimits{
+			MaxOutputSize: maxRenderOutputSize,
+			Timeout:       renderTimeout,
 		},
+		MaxResources: maxResources,
 	}).SetupWithManager(mgr, concurrent); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ObjectTemplate")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err = (&templatesv1alpha1.ObjectTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ObjectTemplate")
+			os.Exit(1)
+		}
+	}
 	if err = (&controllers.TextTemplateReconciler{
 		BaseTemplateReconciler: controllers.BaseTemplateReconciler{
-			Client:       mgr.GetClient(),
-			Scheme:       mgr.GetScheme(),
-			FieldManager: fieldManager,
+			Client:            mgr.GetClient(),
+			Scheme:            mgr.GetScheme(),
+			FieldManager:      fieldManager,
+			ControllerName:    controllerName,
+			ControllerVersion: version,
+			AllowedNamespaces: watchNamespaces,
+			WatchDelay:        watchDelay,
+			Shard:             shard,
+			IntervalJitter:    intervalJitter,
 		},
 	}).SetupWithManager(mgr, concurrent); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "TextTemplate")
 		os.Exit(1)
 	}
-	if err = (&objecthandler.ObjectHandlerReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		FieldManager: fieldManager,
+	if err = (&controllers.MatrixTextTemplateReconciler{
+		BaseTemplateReconciler: controllers.BaseTemplateReconciler{
+			Client:            mgr.GetClient(),
+			Scheme:            mgr.GetScheme(),
+			FieldManager:      fieldManager,
+			ControllerName:    controllerName,
+			ControllerVersion: version,
+			AllowedNamespaces: watchNamespaces,
+			WatchDelay:        watchDelay,
+			Shard:             shard,
+			IntervalJitter:    intervalJitter,
+		},
+		TmpBaseDir:    filepath.Join(os.TempDir(), "template-controller"),
+		EventRecorder: mgr.GetEventRecorderFor("template-controller"),
+		RenderLimits: &controllers.RenderLimits{
+			MaxOutputSize: maxRenderOutputSize,
+			Timeout:       renderTimeout,
+		},
 	}).SetupWithManager(mgr, concurrent); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ObjectHandler")
-		os.Exit(1)
-	}
-	if err = (&controllers.ListGitlabMergeRequestsReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		FieldManager: fieldManager,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ListGitlabMergeRequests")
-		os.Exit(1)
-	}
-	if err = (&controllers.ListGithubPullRequestsReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		FieldManager: fieldManager,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ListGithubPullRequests")
-		os.Exit(1)
-	}
-	if err = (&controllers.GitProjectorReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		FieldManager: fieldManager,
-		TmpBaseDir:   filepath.Join(os.TempDir(), "template-controller"),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "GitProjector")
+		setupLog.Error(err, "unable to create controller", "controller", "MatrixTextTemplate")
 		os.Exit(1)
 	}
-	if err = (&comments.GitlabCommentReconciler{
-		BaseCommentReconciler: comments.BaseCommentReconciler{
+	// the remaining controllers are not sharded and must only run once
+	if shard == "" {
+		if err = (&objecthandler.ObjectHandlerReconciler{
 			Client:       mgr.GetClient(),
 			Scheme:       mgr.GetScheme(),
 			FieldManager: fieldManager,
-		},
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "GitlabComment")
-		os.Exit(1)
-	}
-	if err = (&comments.GithubCommentReconciler{
-		BaseCommentReconciler: comments.BaseCommentReconciler{
+		}).SetupWithManager(mgr, concurrent); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ObjectHandler")
+			os.Exit(1)
+		}
+		if err = (&controllers.ListGitlabMergeRequestsReconciler{
 			Client:       mgr.GetClient(),
 			Scheme:       mgr.GetScheme(),
 			FieldManager: fieldManager,
-		},
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "GithubComment")
-		os.Exit(1)
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ListGitlabMergeRequests")
+			os.Exit(1)
+		}
+		if err = (&controllers.ListGithubPullRequestsReconciler{
+			Client:       mgr.GetClient(),
+			Scheme:       mgr.GetScheme(),
+			FieldManager: fieldManager,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ListGithubPullRequests")
+			os.Exit(1)
+		}
+		if err = (&controllers.GitProjectorReconciler{
+			Client:       mgr.GetClient(),
+			Scheme:       mgr.GetScheme(),
+			FieldManager: fieldManager,
+			TmpBaseDir:   filepath.Join(os.TempDir(), "template-controller"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GitProjector")
+			os.Exit(1)
+		}
+		if err = (&comments.GitlabCommentReconciler{
+			BaseCommentReconciler: comments.BaseCommentReconciler{
+				Client:       mgr.GetClient(),
+				Scheme:       mgr.GetScheme(),
+				FieldManager: fieldManager,
+			},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GitlabComment")
+			os.Exit(1)
+		}
+		if err = (&comments.GithubCommentReconciler{
+			BaseCommentReconciler: comments.BaseCommentReconciler{
+				Client:       mgr.GetClient(),
+				Scheme:       mgr.GetScheme(),
+				FieldManager: fieldManager,
+			},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GithubComment")
+			os.Exit(1)
+		}
 	}
 	//+kubebuilder:scaffold:builder
 
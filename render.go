@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/kluctl/template-controller/controllers"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	templatesv1alpha1 "github.com/kluctl/template-controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// stringsFlag is a flag that can be specified multiple times
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// runRender implements the render subcommand, which renders an ObjectTemplate read from a file without cluster access
+// and prints all objects that would be applied as multi-document YAML to stdout
+func runRender(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	var file string
+	var inputFiles stringsFlag
+	var namespace string
+	var enableCustomJinja2Filters bool
+	var renderTimeout time.Duration
+	fs.StringVar(&file, "file", "",
+		"The file containing the ObjectTemplate. All other documents in this file are used as inputs, the same way "+
+			"as with --input.")
+	fs.Var(&inputFiles, "input", "A file with objects (e.g. ConfigMaps, Secrets or CRDs) that are used instead of the "+
+		"objects on the cluster when loading matrix inputs, vars, filters and template ConfigMaps. Can be specified "+
+		"multiple times.")
+	fs.StringVar(&namespace, "namespace", "",
+		"The namespace of the ObjectTemplate. Defaults to the namespace specified in the file or 'default'.")
+	fs.BoolVar(&enableCustomJinja2Filters, "enable-custom-jinja2-filters", false,
+		"Allow loading custom Jinja2 filters from ConfigMaps, see the controller flag with the same name.")
+	fs.DurationVar(&renderTimeout, "render-timeout", time.Minute,
+		"The maximum duration of rendering a single template. Set to 0 to disable the limit.")
+	// only errors are logged by default, as the logs of the controller are not meaningful without a cluster
+	opts := zap.Options{
+		Level: zapcore.ErrorLevel,
+	}
+	opts.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "--file must be specified")
+		return 2
+	}
+
+	// logs go to stderr, so that stdout only contains the rendered objects
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	rt, inputs, err := loadRenderInputs(file, inputFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
+	if namespace != "" {
+		rt.SetNamespace(namespace)
+	} else if rt.GetNamespace() == "" {
+		rt.SetNamespace("default")
+	}
+
+	r := &controllers.ObjectTemplateReconciler{
+		BaseTemplateReconciler: controllers.BaseTemplateReconciler{
+			Scheme:            scheme,
+			FieldManager:      "template-controller",
+			ControllerName:    "template-controller",
+			ControllerVersion: version,
+		},
+		EnableCustomJinja2Filters: enableCustomJinja2Filters,
+		TmpBaseDir:                filepath.Join(os.TempDir(), "template-controller"),
+		RenderLimits: &controllers.RenderLimits{
+			Timeout: renderTimeout,
+		},
+	}
+	objs, err := r.RenderOffline(context.Background(), rt, inputs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
+
+	var buf bytes.Buffer
+	for _, x := range objs {
+		b, err := sigsyaml.Marshal(x.Object)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return 1
+		}
+		buf.WriteString("---\n")
+		buf.Write(b)
+	}
+	_, _ = stdout.Write(buf.Bytes())
+	return 0
+}
+
+// loadRenderInputs reads the ObjectTemplate from file and all other objects from file and inputFiles
+func loadRenderInputs(file string, inputFiles []string) (*templatesv1alpha1.ObjectTemplate, []*unstructured.Unstructured, error) {
+	var rt *templatesv1alpha1.ObjectTemplate
+	var inputs []*unstructured.Unstructured
+	for _, f := range append([]string{file}, inputFiles...) {
+		objs, err := readObjectsFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, x := range objs {
+			if f == file && x.GroupVersionKind() == templatesv1alpha1.GroupVersion.WithKind("ObjectTemplate") {
+				if rt != nil {
+					return nil, nil, fmt.Errorf("%s contains more than one ObjectTemplate", f)
+				}
+				rt = &templatesv1alpha1.ObjectTemplate{}
+				err = runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(x.Object, rt, true)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid ObjectTemplate in %s: %w", f, err)
+				}
+				continue
+			}
+			inputs = append(inputs, x)
+		}
+	}
+	if rt == nil {
+		return nil, nil, fmt.Errorf("%s does not contain an ObjectTemplate", file)
+	}
+	return rt, inputs, nil
+}
+
+func readObjectsFile(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []*unstructured.Unstructured
+	d := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var u unstructured.Unstructured
+		err = d.Decode(&u.Object)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if len(u.Object) == 0 {
+			// empty documents
+			continue
+		}
+		ret = append(ret, &u)
+	}
+	return ret, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestRenderSubcommand(t *testing.T) {
+	expected, err := os.ReadFile("testdata/render/expected.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	code := runRender([]string{
+		"--file", "testdata/render/objecttemplate.yaml",
+		"--input", "testdata/render/inputs.yaml",
+	}, &stdout)
+	if code != 0 {
+		t.Fatalf("render failed with exit code %d", code)
+	}
+	if stdout.String() != string(expected) {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}